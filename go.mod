@@ -21,6 +21,7 @@ require (
 	github.com/stretchr/testify v1.5.1
 	github.com/timfpark/conjungo v1.0.1
 	github.com/timfpark/yaml v0.0.0-20190612232118-2e9e29c9df01
+	github.com/xeipuuv/gojsonschema v1.2.0
 	golang.org/x/crypto v0.0.0-20200414173820-0848c9571904 // indirect
 	golang.org/x/net v0.0.0-20191004110552-13f9640d40b9 // indirect
 	golang.org/x/sys v0.0.0-20191022100944-742c48ecaeb7 // indirect
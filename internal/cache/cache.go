@@ -0,0 +1,329 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/fabrikate/internal/url"
+)
+
+const (
+	// GitKind is the kind of cache entries holding a git repository checked out at a commit
+	GitKind = "git"
+	// HelmKind is the kind of cache entries holding a helm chart archive of a version
+	HelmKind = "helm"
+	// HTTPKind is the kind of cache entries holding a manifest downloaded over http
+	HTTPKind = "http"
+	// SchemaKind is the kind of cache entries holding a JSON schema of Kubernetes objects downloaded over http
+	SchemaKind = "schema"
+
+	// HelmArchiveFilename is the name of the chart archive in a helm cache entry
+	HelmArchiveFilename = "chart.tgz"
+	// HTTPManifestFilename is the name of the downloaded manifest in a http cache entry
+	HTTPManifestFilename = "manifest.yaml"
+	// SchemaFilename is the name of the downloaded schema in a schema cache entry
+	SchemaFilename = "schema.json"
+)
+
+// ErrNotCached is returned when a source is required to be in the cache but is not
+var ErrNotCached = errors.New("not found in install cache")
+
+// Thread safe store of the cache directory override
+type cacheDir struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+var overrideDir = cacheDir{}
+
+// SetDir overrides the directory the cache is stored in. An empty `dir`
+// restores the default.
+func SetDir(dir string) {
+	overrideDir.mu.Lock()
+	overrideDir.dir = dir
+	overrideDir.mu.Unlock()
+}
+
+// Dir returns the directory the cache is stored in; the directory set via
+// SetDir if any, otherwise `$XDG_CACHE_HOME/fabrikate` (or the platform
+// equivalent).
+func Dir() (string, error) {
+	overrideDir.mu.RLock()
+	dir := overrideDir.dir
+	overrideDir.mu.RUnlock()
+
+	if dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(userCacheDir, "fabrikate"), nil
+}
+
+// kindDir returns the directory entries of the given `kind` are stored in.
+func kindDir(kind string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, kind), nil
+}
+
+// GitPath returns the path of the cache entry for `repo` checked out at the
+// full commit SHA `commit`.
+func GitPath(repo string, commit string) (string, error) {
+	dir, err := kindDir(GitKind)
+	if err != nil {
+		return "", err
+	}
+
+	repoPath, err := url.ToPath(repo)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, repoPath, commit), nil
+}
+
+// HelmPath returns the path of the cache entry for version `version` of the
+// helm chart `chart` from the helm repository `repo`. The version of a chart
+// in an OCI registry may be a digest (`sha256:<hex>`), keying the entry by it.
+func HelmPath(repo string, chart string, version string) (string, error) {
+	dir, err := kindDir(HelmKind)
+	if err != nil {
+		return "", err
+	}
+
+	repoPath, err := url.ToPath(repo)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, repoPath, chart, strings.TrimPrefix(version, "sha256:")), nil
+}
+
+// HTTPPath returns the path of the cache entry for the manifest downloaded
+// from `source` with the content digest `digest` (`sha256:<hex>`).
+func HTTPPath(source string, digest string) (string, error) {
+	dir, err := kindDir(HTTPKind)
+	if err != nil {
+		return "", err
+	}
+
+	sourcePath, err := url.ToPath(source)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, sourcePath, strings.TrimPrefix(digest, "sha256:")), nil
+}
+
+// SchemaPath returns the path of the cache entry for the JSON schema
+// downloaded from `source`.
+func SchemaPath(source string) (string, error) {
+	dir, err := kindDir(SchemaKind)
+	if err != nil {
+		return "", err
+	}
+
+	sourcePath, err := url.ToPath(source)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, sourcePath), nil
+}
+
+// Lookup returns whether the cache entry at `entryPath` exists, marking it as
+// used if it does.
+func Lookup(entryPath string) bool {
+	if _, err := os.Stat(entryPath); err != nil {
+		return false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(entryPath, now, now)
+
+	return true
+}
+
+// TempDir creates a new temporary directory inside the cache which can be
+// atomically moved into place with Store.
+func TempDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	tmpDir := path.Join(dir, "tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", err
+	}
+
+	return ioutil.TempDir(tmpDir, "")
+}
+
+// Store moves `from` (a path created under TempDir) to the cache entry at
+// `entryPath`. If the entry already exists, `from` is removed and the existing
+// entry is kept.
+func Store(from string, entryPath string) error {
+	if Lookup(entryPath) {
+		return os.RemoveAll(from)
+	}
+
+	if err := os.MkdirAll(path.Dir(entryPath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(from, entryPath); err != nil {
+		// Another process may have stored the same entry in the meantime
+		if Lookup(entryPath) {
+			return os.RemoveAll(from)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Entry is a single entry in the cache.
+type Entry struct {
+	Kind     string
+	Source   string
+	Key      string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+}
+
+// List returns all entries in the cache, sorted by kind, source, and key.
+func List() (entries []Entry, err error) {
+	for _, kind := range []string{GitKind, HelmKind, HTTPKind, SchemaKind} {
+		dir, err := kindDir(kind)
+		if err != nil {
+			return nil, err
+		}
+
+		err = filepath.Walk(dir, func(entryPath string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() || !isEntry(kind, entryPath) {
+				return nil
+			}
+
+			relativePath, err := filepath.Rel(dir, entryPath)
+			if err != nil {
+				return err
+			}
+			size, err := dirSize(entryPath)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, Entry{
+				Kind:     kind,
+				Source:   filepath.ToSlash(filepath.Dir(relativePath)),
+				Key:      filepath.Base(relativePath),
+				Path:     entryPath,
+				Size:     size,
+				LastUsed: info.ModTime(),
+			})
+
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.Join([]string{entries[i].Kind, entries[i].Source, entries[i].Key}, "/") < strings.Join([]string{entries[j].Kind, entries[j].Source, entries[j].Key}, "/")
+	})
+
+	return entries, nil
+}
+
+// isEntry returns whether the directory at `dirPath` is a cache entry of `kind`.
+func isEntry(kind string, dirPath string) bool {
+	marker := ".git"
+	switch kind {
+	case HelmKind:
+		marker = HelmArchiveFilename
+	case HTTPKind:
+		marker = HTTPManifestFilename
+	case SchemaKind:
+		marker = SchemaFilename
+	}
+
+	_, err := os.Stat(path.Join(dirPath, marker))
+	return err == nil
+}
+
+// dirSize returns the total size of all files under `dirPath`.
+func dirSize(dirPath string) (size int64, err error) {
+	err = filepath.Walk(dirPath, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// Prune removes all entries in the cache not used within `maxAge`.
+//
+// Returns the removed entries.
+func Prune(maxAge time.Duration) (pruned []Entry, err error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.LastUsed.Before(cutoff) {
+			if err := os.RemoveAll(entry.Path); err != nil {
+				return pruned, err
+			}
+			pruned = append(pruned, entry)
+		}
+	}
+
+	return pruned, nil
+}
+
+// Clear removes the entire cache directory.
+func Clear() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// String returns a human readable description of the entry.
+func (e Entry) String() string {
+	return fmt.Sprintf("%s %s@%s", e.Kind, e.Source, e.Key)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	SetDir(dir)
+	defer SetDir("")
+
+	gitPath, err := GitPath("https://github.com/microsoft/fabrikate-definitions", "0123456789abcdef0123456789abcdef01234567")
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(dir, "git/github.com/microsoft/fabrikate-definitions/0123456789abcdef0123456789abcdef01234567"), gitPath)
+
+	helmPath, err := HelmPath("https://charts.example.com/stable", "grafana", "1.0.0")
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(dir, "helm/charts.example.com/stable/grafana/1.0.0"), helmPath)
+
+	// Nothing is cached yet
+	assert.False(t, Lookup(gitPath))
+	entries, err := List()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	// Store a git and a helm entry
+	tmpGit, err := TempDir()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Mkdir(path.Join(tmpGit, ".git"), 0755))
+	assert.Nil(t, Store(tmpGit, gitPath))
+
+	tmpHelm, err := TempDir()
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(path.Join(tmpHelm, HelmArchiveFilename), []byte("chart"), 0644))
+	assert.Nil(t, Store(tmpHelm, helmPath))
+
+	assert.True(t, Lookup(gitPath))
+	entries, err = List()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, GitKind, entries[0].Kind)
+	assert.Equal(t, "github.com/microsoft/fabrikate-definitions", entries[0].Source)
+	assert.Equal(t, HelmKind, entries[1].Kind)
+	assert.Equal(t, "charts.example.com/stable/grafana", entries[1].Source)
+	assert.Equal(t, "1.0.0", entries[1].Key)
+	assert.Equal(t, int64(5), entries[1].Size)
+
+	// Storing an existing entry keeps the existing one
+	tmpDuplicate, err := TempDir()
+	assert.Nil(t, err)
+	assert.Nil(t, Store(tmpDuplicate, helmPath))
+	_, err = os.Stat(tmpDuplicate)
+	assert.True(t, os.IsNotExist(err))
+
+	// Prune only removes entries which were not used recently
+	lastMonth := time.Now().Add(-30 * 24 * time.Hour)
+	assert.Nil(t, os.Chtimes(helmPath, lastMonth, lastMonth))
+	pruned, err := Prune(7 * 24 * time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pruned))
+	assert.Equal(t, HelmKind, pruned[0].Kind)
+	entries, err = List()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	// Clear removes everything
+	assert.Nil(t, Clear())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
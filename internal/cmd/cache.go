@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+// CacheList implements the 'cache list' command. It prints every entry in the install cache.
+func CacheList() (err error) {
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "KIND\tSOURCE\tKEY\tSIZE\tLAST USED")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\n", entry.Kind, entry.Source, entry.Key, entry.Size, entry.LastUsed.Format(time.RFC3339))
+	}
+
+	return writer.Flush()
+}
+
+// CachePrune implements the 'cache prune' command. It removes every entry in the install cache which has
+// not been used within `maxAge`.
+func CachePrune(maxAge time.Duration) (err error) {
+	pruned, err := cache.Prune(maxAge)
+	for _, entry := range pruned {
+		logger.Info(emoji.Sprintf(":bomb: Removed %s", entry))
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info(emoji.Sprintf(":white_check_mark: Pruned %d entries not used in %s", len(pruned), maxAge))
+
+	return nil
+}
+
+// CacheClear implements the 'cache clear' command. It removes the entire install cache.
+func CacheClear() (err error) {
+	dir, err := cache.Dir()
+	if err != nil {
+		return err
+	}
+
+	logger.Info(emoji.Sprintf(":bomb: Removing install cache '%s'", dir))
+	if err = cache.Clear(); err != nil {
+		return err
+	}
+
+	logger.Info(emoji.Sprintf(":white_check_mark: Completed cache clear!"))
+
+	return nil
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache <list|prune|clear>",
+	Short: "Manages the install cache of git repositories and helm charts shared across runs.",
+	Long: `Manages the install cache of git repositories and helm charts shared across runs.
+
+'install' stores every git repository it clones by repository and commit, and every helm chart it pulls by
+repository, chart, and version, in the install cache. Later installs of the same commit or chart version are
+copied from the cache instead of being fetched again.
+
+The cache is stored in $XDG_CACHE_HOME/fabrikate (or the platform equivalent) unless --cache-dir is passed.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("'cache' requires one of the subcommands: list, prune, clear")
+	},
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the entries in the install cache.",
+	Long:  "Lists the entries in the install cache.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return CacheList()
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune [--max-age <duration>]",
+	Short: "Removes entries from the install cache which have not been used recently.",
+	Long: `Removes entries from the install cache which have not been used recently.
+
+example:
+
+$ fab cache prune --max-age 168h
+
+Removes all git repositories and helm charts that have not been used by an install in the last week.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxAge, err := cmd.Flags().GetDuration("max-age")
+		if err != nil {
+			return err
+		}
+
+		return CachePrune(maxAge)
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Removes all entries from the install cache.",
+	Long:  "Removes all entries from the install cache.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return CacheClear()
+	},
+}
+
+func init() {
+	cachePruneCmd.Flags().Duration("max-age", 30*24*time.Hour, "Remove entries not used within this duration")
+
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/git"
+	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/microsoft/fabrikate/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+// DiffOptions specify what the manifests generated for `Environments` are
+// compared against; exactly one of `AgainstEnvironments`, `AgainstDir`, and
+// `AgainstRef` is expected to be set.
+type DiffOptions struct {
+	Environments        []string
+	AgainstEnvironments []string
+	AgainstDir          string
+	AgainstRef          string
+}
+
+// generateObjects generates the component tree at `startPath` with the given
+// environments and parses the generated manifests into objects.
+func generateObjects(startPath string, environments []string) (objects []manifest.Object, err error) {
+	components, err := GenerateComponents(startPath, environments)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, component := range components {
+		for _, skippedManifest := range component.SkippedManifests {
+			logger.Warn(emoji.Sprintf(":lock: Encrypted manifest %s of component '%s' is left out of the diff; only generate decrypts manifests", skippedManifest, component.Name))
+		}
+
+		componentObjects, err := manifest.Parse(component.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing manifests generated for component '%s': %w", component.Name, err)
+		}
+		objects = append(objects, componentObjects...)
+	}
+
+	return objects, nil
+}
+
+// refObjects installs and generates the component tree at `startPath` as of
+// the git ref `ref` with the given environments.
+func refObjects(startPath string, ref string, environments []string) (objects []manifest.Object, err error) {
+	worktreePath, removeWorktree, err := git.Worktree(startPath, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if removeErr := removeWorktree(); removeErr != nil {
+			logger.Warn(emoji.Sprintf(":question: Unable to remove git worktree of '%s': %s", ref, removeErr))
+		}
+	}()
+
+	if err := Install(worktreePath, false); err != nil {
+		return nil, err
+	}
+
+	return generateObjects(worktreePath, environments)
+}
+
+// Diff implements the 'diff' command. It generates the component tree at `startPath` with the
+// environments in `opts` and compares the generated objects, by apiVersion, kind, namespace, and
+// name, against those generated for other environments, those in a previously generated directory,
+// or those generated from a git ref.
+func Diff(startPath string, opts DiffOptions) (diff manifest.Diff, err error) {
+	var before []manifest.Object
+	switch {
+	case opts.AgainstDir != "":
+		logger.Info(emoji.Sprintf(":mag: Comparing against manifests in '%s'", opts.AgainstDir))
+		before, err = manifest.ParseDir(opts.AgainstDir)
+	case opts.AgainstRef != "":
+		logger.Info(emoji.Sprintf(":mag: Comparing against git ref '%s'", opts.AgainstRef))
+		before, err = refObjects(startPath, opts.AgainstRef, opts.Environments)
+	default:
+		logger.Info(emoji.Sprintf(":mag: Comparing against environments '%s'", strings.Join(opts.AgainstEnvironments, ",")))
+		before, err = generateObjects(startPath, opts.AgainstEnvironments)
+	}
+	if err != nil {
+		return diff, err
+	}
+
+	after, err := generateObjects(startPath, opts.Environments)
+	if err != nil {
+		return diff, err
+	}
+
+	return manifest.Compare(before, after), nil
+}
+
+// printDiff writes a human readable report of `diff` to `out`.
+func printDiff(out io.Writer, diff manifest.Diff) {
+	for _, id := range diff.Added {
+		fmt.Fprintf(out, "+ %s\n", id)
+	}
+	for _, id := range diff.Removed {
+		fmt.Fprintf(out, "- %s\n", id)
+	}
+	for _, change := range diff.Changed {
+		fmt.Fprintf(out, "~ %s\n", change.Identity)
+		for _, field := range change.Fields {
+			fmt.Fprintf(out, "    %s\n", field)
+		}
+	}
+
+	fmt.Fprintf(out, "%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
+
+// splitEnvironments splits a comma separated list of environments.
+func splitEnvironments(environments string) []string {
+	split := []string{}
+	for _, environment := range strings.Split(environments, ",") {
+		if environment = strings.TrimSpace(environment); environment != "" {
+			split = append(split, environment)
+		}
+	}
+
+	return split
+}
+
+// diffOptions returns the options of the 'diff' command given its arguments:
+// with two lists of configs, the second is compared against the first.
+func diffOptions(args []string, againstDir string, againstRef string) (opts DiffOptions, err error) {
+	opts = DiffOptions{AgainstDir: againstDir, AgainstRef: againstRef}
+	switch {
+	case len(args) == 2 && againstDir == "" && againstRef == "":
+		opts.AgainstEnvironments = splitEnvironments(args[0])
+		opts.Environments = splitEnvironments(args[1])
+	case len(args) == 1 && (againstDir == "") != (againstRef == ""):
+		opts.Environments = splitEnvironments(args[0])
+	default:
+		return opts, errors.New("diff takes either two lists of configs, or one list of configs and one of --dir or --ref")
+	}
+
+	return opts, nil
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <config1,...,configN> [<config1,...,configN>]",
+	Short: "Compares the resource manifests generated for two sets of configs, or against a previous generation.",
+	Long: `Compares the Kubernetes objects generated from the deployment definition, keyed by apiVersion, kind,
+namespace, and name, and reports every added, removed, and changed object with the fields which changed.
+
+Each argument is a comma separated list of configs, in priority order as for generate. With two arguments,
+the objects generated for the second are compared against those generated for the first: objects only
+generated for the second are reported as added, and objects only generated for the first as removed. With
+one argument, the objects generated for it are compared against either the manifests in a previously generated
+directory (--dir) or those generated from the definition as of a git ref (--ref).
+
+example:
+
+$ fab diff prod staging
+$ fab diff prod,east --dir generated/prod-east
+$ fab diff prod --ref origin/master
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := diffOptions(args, cmd.Flag("dir").Value.String(), cmd.Flag("ref").Value.String())
+		if err != nil {
+			return err
+		}
+
+		diff, err := Diff("./", opts)
+		if err != nil {
+			return err
+		}
+
+		printDiff(os.Stdout, diff)
+
+		if cmd.Flag("exit-code").Value.String() == "true" && !diff.Empty() {
+			return errors.New("generated manifests differ")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.PersistentFlags().String("dir", "", "Compare against the manifests in a previously generated directory")
+	diffCmd.PersistentFlags().String("ref", "", "Compare against the manifests generated from the definition as of a git ref")
+	diffCmd.PersistentFlags().Bool("exit-code", false, "Exit with an error if the generated manifests differ")
+	rootCmd.AddCommand(diffCmd)
+}
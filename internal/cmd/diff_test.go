@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	// 'monitoring' is disabled in staging
+	diff, err := Diff("../../testdata/diff", DiffOptions{
+		AgainstEnvironments: []string{"prod"},
+		Environments:        []string{"staging"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(diff.Added))
+	assert.Equal(t, 1, len(diff.Removed))
+	assert.Equal(t, "Namespace", diff.Removed[0].Kind)
+	assert.Equal(t, 0, len(diff.Changed))
+
+	// Against a previously generated directory
+	diff, err = Diff("../../testdata/diff", DiffOptions{
+		AgainstDir:   "../../testdata/diff/baseline",
+		Environments: []string{"prod"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(diff.Added))
+	assert.Equal(t, "monitoring", diff.Added[0].Name)
+	assert.Equal(t, 1, len(diff.Removed))
+	assert.Equal(t, "Service", diff.Removed[0].Kind)
+	assert.Equal(t, 1, len(diff.Changed))
+	assert.Equal(t, "spec.replicas", diff.Changed[0].Fields[0].Path)
+	assert.Equal(t, "spec.template.spec.containers[0].image", diff.Changed[0].Fields[1].Path)
+}
+
+func TestDiffDirection(t *testing.T) {
+	// `fab diff prod staging` compares staging against prod: 'monitoring' is
+	// only generated for prod, so is removed
+	opts, err := diffOptions([]string{"prod", "staging"}, "", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"prod"}, opts.AgainstEnvironments)
+	assert.Equal(t, []string{"staging"}, opts.Environments)
+
+	diff, err := Diff("../../testdata/diff", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(diff.Added))
+	assert.Equal(t, 1, len(diff.Removed))
+	assert.Equal(t, "monitoring", diff.Removed[0].Name)
+
+	// and the other way around, added
+	opts, err = diffOptions([]string{"staging", "prod"}, "", "")
+	assert.Nil(t, err)
+	diff, err = Diff("../../testdata/diff", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(diff.Added))
+	assert.Equal(t, "monitoring", diff.Added[0].Name)
+	assert.Equal(t, 0, len(diff.Removed))
+
+	_, err = diffOptions([]string{"prod", "staging"}, "generated/prod", "")
+	assert.NotNil(t, err)
+}
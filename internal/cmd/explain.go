@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/spf13/cobra"
+)
+
+// findComponent walks the component tree at `startPath` with the config of the given environments
+// and returns the (sub)component at `subcomponentPath`; the root component if empty.
+func findComponent(startPath string, environments []string, subcomponentPath []string) (component core.Component, err error) {
+	rootInit := func(startPath string, environments []string, c core.Component) (component core.Component, err error) {
+		return c.UpdateComponentPath(startPath, environments)
+	}
+
+	results := core.WalkComponentTree(startPath, environments, func(path string, component *core.Component) (err error) {
+		return nil
+	}, rootInit)
+
+	components, err := core.SynchronizeWalkResult(results)
+	if err != nil {
+		return component, err
+	}
+
+	for _, component := range components {
+		if strings.Join(component.SubcomponentPath, ".") == strings.Join(subcomponentPath, ".") {
+			return component, nil
+		}
+	}
+
+	return component, fmt.Errorf("subcomponent '%s' not found or disabled", strings.Join(subcomponentPath, "."))
+}
+
+// Explain implements the 'explain' command. It merges the config of the given environments for the
+// (sub)component at `subcomponent` and returns the merged value at the config `path`, along with the
+// config files of the component and the overrides of its ancestors which set it, in order of precedence.
+func Explain(startPath string, environments []string, subcomponent string, path string) (explanation core.ConfigExplanation, err error) {
+	subcomponentPath := []string{}
+	if len(subcomponent) > 0 {
+		subcomponentPath = strings.Split(subcomponent, ".")
+	}
+
+	configPath, err := SplitPathParts(path)
+	if err != nil {
+		return explanation, err
+	}
+
+	component, err := findComponent(startPath, environments, subcomponentPath)
+	if err != nil {
+		return explanation, err
+	}
+
+	return component.ExplainConfig(configPath)
+}
+
+// printExplanation writes a human readable report of `explanation` to `out`.
+func printExplanation(out io.Writer, explanation core.ConfigExplanation) {
+	path := core.JoinConfigPath(explanation.Path)
+	if !explanation.Set {
+		fmt.Fprintf(out, "%s is not set for component '%s'\n", path, explanation.Component)
+		return
+	}
+
+	fmt.Fprintf(out, "%s = %s (component '%s')\n", path, formatConfigValue(explanation.Value), explanation.Component)
+	for index, valueSource := range explanation.Sources {
+		source := valueSource.Source
+		origin := "config"
+		switch {
+		case source.Global && source.IsOverride():
+			origin = fmt.Sprintf("global override from component '%s'", source.Component)
+		case source.Global:
+			origin = fmt.Sprintf("global of component '%s'", source.Component)
+		case source.IsOverride():
+			origin = fmt.Sprintf("override from component '%s'", source.Component)
+		}
+
+		fmt.Fprintf(out, "  %d. %-8s %s (%s)\n", index+1, valueSource.Effect, source.File, origin)
+		fmt.Fprintf(out, "       %s = %s\n", source.KeyPath(explanation.Path), formatConfigValue(valueSource.Value))
+	}
+}
+
+// formatConfigValue formats a config value as compact JSON.
+func formatConfigValue(value interface{}) string {
+	formatted, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(formatted)
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <config1> <config2> ... <configN> --path <path> [--subcomponent subcomponent]",
+	Short: "Explains where the merged value of a config path of a component comes from.",
+	Long: `Explains where the merged value of a config path of a component comes from.
+
+The configs are merged in priority order as for generate, and the merged value is printed along with every
+config file which sets it, in order of precedence: the overrides in the 'subcomponents' sections of the
+configs of the ancestors of the component first, then the configs of the component itself and finally its
+'common' config, followed likewise by the files setting it in the 'global' config of the component and then
+of each of its ancestors, which only apply where the files before them do not set it. Each file is marked
+with whether it defines the value, merges into it (maps and lists), or is shadowed by the files before it.
+
+example:
+
+$ fab explain prod east --subcomponent "myapp.mysubcomponent" --path data.replicas
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := cmd.Flag("path").Value.String()
+		if path == "" {
+			return errors.New("'explain' requires a config --path")
+		}
+
+		explanation, err := Explain("./", args, cmd.Flag("subcomponent").Value.String(), path)
+		if err != nil {
+			return err
+		}
+
+		printExplanation(os.Stdout, explanation)
+		return nil
+	},
+}
+
+func init() {
+	explainCmd.PersistentFlags().String("subcomponent", "", "Subcomponent to explain the config of")
+	explainCmd.PersistentFlags().String("path", "", "Config path to explain, eg. data.replicas")
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	explanation, err := Explain("../../testdata/explain", []string{"prod"}, "app.web", "data.replicas")
+	assert.Nil(t, err)
+	assert.True(t, explanation.Set)
+	assert.EqualValues(t, 3, explanation.Value)
+	assert.Equal(t, 4, len(explanation.Sources))
+
+	// Overrides of the ancestors first, then the config of the component itself
+	assert.Equal(t, "../../testdata/explain/config/prod.yaml", explanation.Sources[0].Source.File)
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "subcomponents.app.subcomponents.web.config.data.replicas", explanation.Sources[0].Source.KeyPath(explanation.Path))
+	assert.Equal(t, "../../testdata/explain/config/common.yaml", explanation.Sources[1].Source.File)
+	assert.Equal(t, "shadowed", explanation.Sources[1].Effect)
+	assert.Equal(t, "app", explanation.Sources[2].Source.Component)
+	assert.Equal(t, "shadowed", explanation.Sources[2].Effect)
+	assert.False(t, explanation.Sources[3].Source.IsOverride())
+	assert.Equal(t, "shadowed", explanation.Sources[3].Effect)
+
+	// Lists are appended to
+	explanation, err = Explain("../../testdata/explain", []string{"prod"}, "app.web", "hosts")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"app.example.com", "web.example.com"}, explanation.Value)
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "merges", explanation.Sources[1].Effect)
+
+	explanation, err = Explain("../../testdata/explain", []string{"prod"}, "app", "data.replicas")
+	assert.Nil(t, err)
+	assert.False(t, explanation.Set)
+	assert.Equal(t, 0, len(explanation.Sources))
+
+	_, err = Explain("../../testdata/explain", []string{"prod"}, "app.db", "data.replicas")
+	assert.NotNil(t, err)
+}
+
+func TestExplainGlobal(t *testing.T) {
+	// Values only set in the global config of an ancestor
+	explanation, err := Explain("../../testdata/global", []string{}, "app.web", "imageRegistry")
+	assert.Nil(t, err)
+	assert.Equal(t, "private.example.com", explanation.Value)
+	assert.Equal(t, 2, len(explanation.Sources))
+	assert.Equal(t, "../../testdata/global/app/config/common.yaml", explanation.Sources[0].Source.File)
+	assert.True(t, explanation.Sources[0].Source.Global)
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "global.imageRegistry", explanation.Sources[0].Source.KeyPath(explanation.Path))
+	assert.Equal(t, "../../testdata/global/config/common.yaml", explanation.Sources[1].Source.File)
+	assert.Equal(t, "global", explanation.Sources[1].Source.Component)
+	assert.Equal(t, "shadowed", explanation.Sources[1].Effect)
+
+	// The config of a component shadows the global config
+	explanation, err = Explain("../../testdata/global", []string{}, "cache", "image.pullPolicy")
+	assert.Nil(t, err)
+	assert.Equal(t, "IfNotPresent", explanation.Value)
+	assert.Equal(t, 2, len(explanation.Sources))
+	assert.False(t, explanation.Sources[0].Source.Global)
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.True(t, explanation.Sources[1].Source.Global)
+	assert.Equal(t, "shadowed", explanation.Sources[1].Effect)
+
+	// and global maps are merged into those of the config
+	explanation, err = Explain("../../testdata/global", []string{}, "app", "image")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"tag": "v1", "pullPolicy": "Always"}, explanation.Value)
+	assert.Equal(t, 2, len(explanation.Sources))
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "merges", explanation.Sources[1].Effect)
+	assert.Equal(t, "global.image", explanation.Sources[1].Source.KeyPath(explanation.Path))
+}
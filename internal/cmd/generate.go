@@ -1,37 +1,318 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/kyokomi/emoji"
 	"github.com/microsoft/fabrikate/internal/core"
 	"github.com/microsoft/fabrikate/internal/generators"
 	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/microsoft/fabrikate/internal/manifest"
 	"github.com/spf13/cobra"
+	"github.com/timfpark/yaml"
 )
 
-func writeGeneratedManifests(generationPath string, components []core.Component) (err error) {
-	// Delete the old version, so we don't end up with a mishmash of two builds.
-	os.RemoveAll(generationPath)
+// generatedFilePath returns the path of the file the manifest of `component` is written to.
+func generatedFilePath(generationPath string, component core.Component) string {
+	return path.Join(generationPath, component.LogicalPath, fmt.Sprintf("%s.yaml", component.Name))
+}
+
+// Layouts of the generated manifests.
+const (
+	LayoutComponent   = "component"    // a <name>.yaml file per component, in the directory of its logical path
+	LayoutSingle      = "single"       // a single manifests.yaml file
+	LayoutPerResource = "per-resource" // a <kind>-<name>.yaml file per object, in a directory per component
+	LayoutKustomize   = "kustomize"    // a file per component, and a kustomization.yaml per directory listing them
+)
+
+// StdoutOutput is the output directory writing the generated manifests to stdout, as a single stream.
+const StdoutOutput = "-"
 
+const (
+	singleManifestFilename = "manifests.yaml"
+	kustomizationFilename  = "kustomization.yaml"
+	generatedListFilename  = ".fabrikate-generated" // lists the files generated into an --output-dir
+)
+
+// manifestStream concatenates the manifests of `components` into a single multi-document YAML.
+func manifestStream(components []core.Component) string {
+	var stream strings.Builder
 	for _, component := range components {
-		componentGenerationPath := path.Join(generationPath, component.LogicalPath)
-		if err = os.MkdirAll(componentGenerationPath, 0777); err != nil {
-			return err
+		if strings.TrimSpace(component.Manifest) == "" {
+			continue
+		}
+		if !strings.HasPrefix(component.Manifest, "---") {
+			stream.WriteString("---\n")
 		}
+		stream.WriteString(component.Manifest)
+		if !strings.HasSuffix(component.Manifest, "\n") {
+			stream.WriteString("\n")
+		}
+	}
 
-		componentYAMLFilename := fmt.Sprintf("%s.yaml", component.Name)
-		componentYAMLFilePath := path.Join(componentGenerationPath, componentYAMLFilename)
+	return stream.String()
+}
+
+// singleStream returns the manifests of `components` as a single multi-document YAML; with `normalize`, the
+// objects of all components are sorted together, such that the order of kinds holds across components.
+func singleStream(components []core.Component, normalize bool) (string, error) {
+	stream := manifestStream(components)
+	if !normalize {
+		return stream, nil
+	}
 
-		logger.Info(emoji.Sprintf(":floppy_disk: Writing %s", componentYAMLFilePath))
+	normalized, err := manifest.Normalize(stream)
+	if err != nil {
+		return "", fmt.Errorf("error normalizing the generated manifests: %w", err)
+	}
+
+	return normalized, nil
+}
 
-		err = ioutil.WriteFile(componentYAMLFilePath, []byte(component.Manifest), 0644)
+// perResourceFiles returns the files of the per-resource layout: the objects generated for each
+// component in a <kind>-<name>.yaml file, or <kind>-<namespace>-<name>.yaml when that is ambiguous.
+func perResourceFiles(generationPath string, components []core.Component) (files map[string]string, err error) {
+	files = map[string]string{}
+	for _, component := range components {
+		objects, err := manifest.Parse(component.Manifest)
 		if err != nil {
+			return nil, fmt.Errorf("error parsing manifests generated for component '%s': %w", component.Name, err)
+		}
+
+		filename := func(id manifest.Identity) string {
+			return strings.ToLower(id.Kind) + "-" + id.Name
+		}
+		count := map[string]int{}
+		for _, object := range objects {
+			count[filename(object.Identity())]++
+		}
+
+		componentPath := path.Join(generationPath, component.LogicalPath, component.Name)
+		for _, object := range objects {
+			id := object.Identity()
+			name := filename(id)
+			if count[name] > 1 && id.Namespace != "" {
+				name = strings.ToLower(id.Kind) + "-" + id.Namespace + "-" + id.Name
+			}
+			objectPath := path.Join(componentPath, name+".yaml")
+			for index := 2; files[objectPath] != ""; index++ {
+				objectPath = path.Join(componentPath, fmt.Sprintf("%s-%d.yaml", name, index))
+			}
+
+			if files[objectPath], err = manifest.Marshal(object); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// kustomizationFiles returns a kustomization.yaml for every directory from `generationPath` down to
+// those of `files`, listing the files and subdirectories within it.
+func kustomizationFiles(generationPath string, files map[string]string) (kustomizations map[string]string, err error) {
+	resources := map[string]map[string]bool{generationPath: {}}
+	for filePath := range files {
+		for dir, resource := path.Dir(filePath), path.Base(filePath); ; dir, resource = path.Dir(dir), path.Base(dir) {
+			if resources[dir] == nil {
+				resources[dir] = map[string]bool{}
+			}
+			resources[dir][resource] = true
+			if dir == generationPath || dir == "." || dir == "/" {
+				break
+			}
+		}
+	}
+
+	kustomizations = map[string]string{}
+	for dir, dirResources := range resources {
+		kustomization := struct {
+			APIVersion string   `yaml:"apiVersion"`
+			Kind       string   `yaml:"kind"`
+			Resources  []string `yaml:"resources"`
+		}{APIVersion: "kustomize.config.k8s.io/v1beta1", Kind: "Kustomization", Resources: []string{}}
+		for resource := range dirResources {
+			kustomization.Resources = append(kustomization.Resources, resource)
+		}
+		sort.Strings(kustomization.Resources)
+
+		marshaled, err := yaml.Marshal(kustomization)
+		if err != nil {
+			return nil, err
+		}
+		kustomizations[path.Join(dir, kustomizationFilename)] = string(marshaled)
+	}
+
+	return kustomizations, nil
+}
+
+// withinDir returns the path of `dir` relative to `parent` if `dir` is `parent` or below it.
+func withinDir(dir string, parent string) (relative string, ok bool) {
+	relative, err := filepath.Rel(parent, dir)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return relative, true
+}
+
+// ownedOutputDir returns whether `outputDir` is within the generated directory of the definition at
+// `startPath`, which belongs to generate and is replaced as a whole.
+func ownedOutputDir(startPath string, outputDir string) (bool, error) {
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return false, err
+	}
+	generatedDir, err := filepath.Abs(path.Join(startPath, "generated"))
+	if err != nil {
+		return false, err
+	}
+
+	_, owned := withinDir(absOutputDir, generatedDir)
+	return owned, nil
+}
+
+// previouslyGenerated returns the files a previous generate wrote to the output directory `dir`, as listed
+// in its generatedListFilename. It returns an error if `dir` holds files without such a list, which
+// generate must not replace.
+func previouslyGenerated(dir string) (files []string, err error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) || (err == nil && len(entries) == 0) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := ioutil.ReadFile(path.Join(dir, generatedListFilename))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("output directory '%s' is not empty and was not written by generate; use an empty directory", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range strings.Split(string(list), "\n") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		if _, ok := withinDir(filepath.Join(dir, file), dir); !ok || filepath.IsAbs(file) {
+			return nil, fmt.Errorf("'%s' listed in '%s' is not within the output directory", file, path.Join(dir, generatedListFilename))
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// clearOutputDir removes the manifests of the previous generate from `dir`: the whole directory if `owned`,
+// otherwise only the files it wrote, such that other files (eg. those of a git checkout) are kept.
+func clearOutputDir(dir string, owned bool) error {
+	if owned {
+		return os.RemoveAll(dir)
+	}
+
+	files, err := previouslyGenerated(dir)
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string]bool{}
+	for _, file := range append(files, generatedListFilename) {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for fileDir := filepath.Dir(file); fileDir != "."; fileDir = filepath.Dir(fileDir) {
+			dirs[fileDir] = true
+		}
+	}
+
+	// Remove the directories left empty, deepest first
+	emptied := []string{}
+	for fileDir := range dirs {
+		emptied = append(emptied, fileDir)
+	}
+	sort.Slice(emptied, func(i, j int) bool { return len(emptied[i]) > len(emptied[j]) })
+	for _, fileDir := range emptied {
+		if entries, err := ioutil.ReadDir(filepath.Join(dir, fileDir)); err == nil && len(entries) == 0 {
+			if err := os.Remove(filepath.Join(dir, fileDir)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeGeneratedManifests writes the manifests generated for `components` to `generationPath`, in `layout`;
+// the single manifest keeps the order of `components`, unless normalized (see singleStream). Unless `owned`
+// (see ownedOutputDir), the files written are listed in generationPath, such that the next generate only
+// replaces those.
+func writeGeneratedManifests(generationPath string, layout string, components []core.Component, normalize bool, owned bool) (err error) {
+	files := map[string]string{}
+	switch layout {
+	case LayoutSingle:
+		if files[path.Join(generationPath, singleManifestFilename)], err = singleStream(components, normalize); err != nil {
+			return err
+		}
+	case LayoutPerResource:
+		if files, err = perResourceFiles(generationPath, sortedByGeneratedFile(generationPath, components)); err != nil {
+			return err
+		}
+	default:
+		for _, component := range components {
+			// kustomize fails on resources without objects
+			if layout != LayoutKustomize || strings.TrimSpace(component.Manifest) != "" {
+				files[generatedFilePath(generationPath, component)] = component.Manifest
+			}
+		}
+		if layout == LayoutKustomize {
+			kustomizations, err := kustomizationFiles(generationPath, files)
+			if err != nil {
+				return err
+			}
+			for kustomizationPath, kustomization := range kustomizations {
+				files[kustomizationPath] = kustomization
+			}
+		}
+	}
+
+	// Delete the old version, so we don't end up with a mishmash of two builds.
+	if err = clearOutputDir(generationPath, owned); err != nil {
+		return err
+	}
+
+	filePaths := []string{}
+	generated := []string{}
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+		relative, _ := filepath.Rel(generationPath, filePath)
+		generated = append(generated, filepath.ToSlash(relative))
+	}
+	sort.Strings(filePaths)
+	sort.Strings(generated)
+	if !owned {
+		filePaths = append(filePaths, path.Join(generationPath, generatedListFilename))
+		files[path.Join(generationPath, generatedListFilename)] = strings.Join(generated, "\n") + "\n"
+	}
+
+	for _, filePath := range filePaths {
+		if err = os.MkdirAll(path.Dir(filePath), 0777); err != nil {
+			return err
+		}
+
+		logger.Info(emoji.Sprintf(":floppy_disk: Writing %s", filePath))
+
+		if err = ioutil.WriteFile(filePath, []byte(files[filePath]), 0644); err != nil {
 			return err
 		}
 	}
@@ -39,9 +320,22 @@ func writeGeneratedManifests(generationPath string, components []core.Component)
 	return nil
 }
 
-func validateGeneratedManifests(generationPath string) (err error) {
+// ValidateOptions are the options of the validation of the generated manifests.
+type ValidateOptions struct {
+	Validator            string   // kubectl, schema, or empty to skip validation
+	KubernetesVersion    string   // version of the schemas to validate against (schema)
+	SchemaLocations      []string // directories, URLs or templates of the schemas (schema)
+	Strict               bool     // disallow fields not in the schemas (schema)
+	IgnoreMissingSchemas bool     // skip objects of kinds without a schema (schema)
+}
+
+func validateGeneratedManifests(generationPath string, layout string) (err error) {
 	logger.Info(emoji.Sprintf(":microscope: Validating generated manifests in path %s", generationPath))
-	if output, err := exec.Command("kubectl", "apply", "--validate=true", "--dry-run", "--recursive", "-f", generationPath).Output(); err != nil {
+	files := []string{"--recursive", "-f", generationPath}
+	if layout == LayoutKustomize {
+		files = []string{"-k", generationPath}
+	}
+	if output, err := exec.Command("kubectl", append([]string{"apply", "--validate=true", "--dry-run"}, files...)...).Output(); err != nil {
 		if ee, ok := err.(*exec.ExitError); ok {
 			logger.Error(fmt.Sprintf("Validating generated manifests failed with: %s: output: %s", ee.Stderr, output))
 			return err
@@ -51,48 +345,408 @@ func validateGeneratedManifests(generationPath string) (err error) {
 	return nil
 }
 
-// Generate implements the 'generate' command. It takes a set of environments and a validation flag
-// and iterates through the component tree, generating components as it reaches them, and writing all
-// of the generated manifests at the very end.
-func Generate(startPath string, environments []string, validate bool) (components []core.Component, err error) {
-	// Iterate through component tree and generate
+// schemaValidator returns the validator of the generated manifests against the schemas of the Kubernetes
+// objects with `validation`.
+func schemaValidator(validation ValidateOptions) *manifest.SchemaValidator {
+	return &manifest.SchemaValidator{
+		KubernetesVersion:    validation.KubernetesVersion,
+		Locations:            validation.SchemaLocations,
+		Strict:               validation.Strict,
+		IgnoreMissingSchemas: validation.IgnoreMissingSchemas,
+		Offline:              core.Offline.Enabled(),
+	}
+}
+
+// validateGeneratedManifestsAgainstSchemas validates the generated manifests against the schemas of
+// the Kubernetes objects, without kubectl or a cluster.
+func validateGeneratedManifestsAgainstSchemas(generationPath string, validation ValidateOptions) (err error) {
+	logger.Info(emoji.Sprintf(":microscope: Validating generated manifests in path %s against the schemas of Kubernetes %s", generationPath, validation.KubernetesVersion))
+	violations, err := schemaValidator(validation).ValidateDir(generationPath)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	failures := []string{}
+	for _, violation := range violations {
+		failures = append(failures, violation.String())
+	}
+
+	return fmt.Errorf("generated manifests do not match their schemas:\n  - %s", strings.Join(failures, "\n  - "))
+}
 
+// generationPath returns the path the manifests generated for `environments` are written to.
+func generationPath(startPath string, environments []string) string {
+	environmentName := strings.Join(environments, "-")
+	if len(environmentName) == 0 {
+		environmentName = "common"
+	}
+
+	return path.Join(startPath, "generated", environmentName)
+}
+
+// GenerateComponents iterates through the component tree at `startPath`, generating the manifests of
+// every component with the config of the given environments. Nothing is written to disk.
+func GenerateComponents(startPath string, environments []string) (components []core.Component, err error) {
+	// Iterate through component tree and generate
 	rootInit := func(startPath string, environments []string, c core.Component) (component core.Component, err error) {
 		return c.UpdateComponentPath(startPath, environments)
 	}
 
+	// Config values may reference the config of any other component, so the merged config of the
+	// whole tree is loaded before any of it is interpolated
+	configured, err := core.SynchronizeWalkResult(core.WalkComponentTree(startPath, environments, func(path string, component *core.Component) error {
+		return nil
+	}, rootInit))
+	if err != nil {
+		return nil, err
+	}
+	resolver := core.NewConfigResolver(configured)
+
 	results := core.WalkComponentTree(startPath, environments, func(path string, component *core.Component) (err error) {
+		if err := resolver.Interpolate(component); err != nil {
+			return err
+		}
 
-		var generator core.Generator
-		switch component.ComponentType {
-		case "helm":
-			generator = &generators.HelmGenerator{}
-		case "static":
-			generator = &generators.StaticGenerator{}
+		generator, err := generators.Get(component.ComponentType)
+		if err != nil {
+			return err
 		}
 
 		return component.Generate(generator)
 	}, rootInit)
 
-	components, err = core.SynchronizeWalkResult(results)
+	return core.SynchronizeWalkResult(results)
+}
+
+// Policies for objects generated by several components.
+const (
+	ConflictsError     = "error"      // fail the generation
+	ConflictsWarn      = "warn"       // write all of them
+	ConflictsFirstWins = "first-wins" // write only that of the component whose generated file comes first
+)
+
+// GenerateOptions are the options of the 'generate' command.
+type GenerateOptions struct {
+	Conflicts           string    // policy for objects generated by several components; defaults to ConflictsWarn
+	Normalize           bool      // sort the objects and keys of the generated manifests
+	StripSourceComments bool      // remove helm's '# Source:' comments from the generated manifests
+	OutputDir           string    // defaults to generated/<environments joined by dashes>; StdoutOutput for Stdout
+	OutputLayout        string    // defaults to LayoutComponent
+	Stdout              io.Writer // where StdoutOutput writes to; defaults to os.Stdout
+	Validation          ValidateOptions
+}
+
+// sortByGeneratedFile sorts `components` by the path of the file their manifest is written to.
+func sortByGeneratedFile(generationPath string, components []core.Component) {
+	sort.SliceStable(components, func(i, j int) bool {
+		return generatedFilePath(generationPath, components[i]) < generatedFilePath(generationPath, components[j])
+	})
+}
+
+// sortedByGeneratedFile returns a copy of `components` sorted by the path of the file their manifest is
+// written to.
+func sortedByGeneratedFile(generationPath string, components []core.Component) []core.Component {
+	sorted := append([]core.Component{}, components...)
+	sortByGeneratedFile(generationPath, sorted)
+
+	return sorted
+}
+
+// sortByWalkOrder sorts `components` in the order of the component tree: every component before its
+// subcomponents, and subcomponents in the order they are walked in, dependencies first (see
+// core.OrderSubcomponents), such that the objects of a dependency are applied before those of the
+// components depending on it.
+func sortByWalkOrder(components []core.Component) {
+	// Keyed by subcomponent path, the position of every subcomponent amongst its ordered siblings
+	positions := map[string]int{}
+	for _, component := range components {
+		ordered, err := core.OrderSubcomponents(component.Name, component.Subcomponents)
+		if err != nil {
+			// The walk fails on dependency cycles already
+			continue
+		}
+		for index, subcomponent := range ordered {
+			subcomponentPath := append(append([]string{}, component.SubcomponentPath...), subcomponent.Name)
+			positions[strings.Join(subcomponentPath, "/")] = index
+		}
+	}
+
+	// treePosition returns the positions of the subcomponents leading from the root component to `c`
+	treePosition := func(c core.Component) []int {
+		treePosition := []int{}
+		for depth := 1; depth <= len(c.SubcomponentPath); depth++ {
+			treePosition = append(treePosition, positions[strings.Join(c.SubcomponentPath[:depth], "/")])
+		}
+		return treePosition
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		first, second := treePosition(components[i]), treePosition(components[j])
+		for index := 0; index < len(first) && index < len(second); index++ {
+			if first[index] != second[index] {
+				return first[index] < second[index]
+			}
+		}
+		return len(first) < len(second)
+	})
+}
+
+// componentKey identifies a component of the tree by the names of the subcomponents leading to it.
+func componentKey(c core.Component) string {
+	return strings.Join(c.SubcomponentPath, "/")
+}
+
+// objectConflict is an object generated by several components.
+type objectConflict struct {
+	identity   manifest.Identity
+	components []core.Component
+}
+
+// String lists the colliding components and their logical paths.
+func (oc objectConflict) String() string {
+	components := []string{}
+	for _, component := range oc.components {
+		components = append(components, fmt.Sprintf("'%s' (%s)", component.Name, path.Join(component.LogicalPath, component.Name)))
+	}
+
+	return fmt.Sprintf("%s is generated by components %s", oc.identity, strings.Join(components, ", "))
+}
+
+// findConflicts returns the objects generated by several of `components`, which are expected to be
+// sorted by generated file.
+func findConflicts(components []core.Component) (conflicts []objectConflict, err error) {
+	generatedBy := map[manifest.Identity][]core.Component{}
+	identities := []manifest.Identity{}
+	for _, component := range components {
+		objects, err := manifest.Parse(component.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing manifests generated for component '%s': %w", component.Name, err)
+		}
+
+		for _, object := range objects {
+			id := object.Identity()
+			if _, seen := generatedBy[id]; !seen {
+				identities = append(identities, id)
+			}
+			generatedBy[id] = append(generatedBy[id], component)
+		}
+	}
+
+	for _, id := range identities {
+		if len(generatedBy[id]) > 1 {
+			conflicts = append(conflicts, objectConflict{identity: id, components: generatedBy[id]})
+		}
+	}
+
+	return conflicts, nil
+}
+
+// resolveConflicts applies the `policy` to the objects generated by several of `components`, which are
+// expected to be sorted by generated file.
+func resolveConflicts(components []core.Component, policy string) (err error) {
+	switch policy {
+	case "", ConflictsError, ConflictsWarn, ConflictsFirstWins:
+	default:
+		return fmt.Errorf("unknown conflicts policy '%s', expected %s, %s, or %s", policy, ConflictsError, ConflictsWarn, ConflictsFirstWins)
+	}
+
+	conflicts, err := findConflicts(components)
+	if err != nil || len(conflicts) == 0 {
+		return err
+	}
+
+	if policy == ConflictsError {
+		failures := []string{}
+		for _, conflict := range conflicts {
+			failures = append(failures, conflict.String())
+		}
+		return fmt.Errorf("objects are generated by several components; exclude them from all but one component, or pass --conflicts:\n  - %s", strings.Join(failures, "\n  - "))
+	}
+
+	// Objects are kept in the first component generating them: keyed by component, the objects to drop
+	// and the component keeping them
+	dropped := map[string]map[manifest.Identity]string{}
+	for _, conflict := range conflicts {
+		logger.Warn(emoji.Sprintf(":warning: Conflict: %s", conflict))
+		if policy != ConflictsFirstWins {
+			continue
+		}
+		for _, component := range conflict.components[1:] {
+			key := path.Join(component.LogicalPath, component.Name)
+			if dropped[key] == nil {
+				dropped[key] = map[manifest.Identity]string{}
+			}
+			dropped[key][conflict.identity] = conflict.components[0].Name
+		}
+	}
+
+	for index := range components {
+		component := &components[index]
+		drop, ok := dropped[path.Join(component.LogicalPath, component.Name)]
+		if !ok {
+			continue
+		}
+
+		var removed []manifest.Identity
+		component.Manifest, removed, err = manifest.Filter(component.Manifest, func(id manifest.Identity) bool {
+			_, ok := drop[id]
+			return ok
+		})
+		if err != nil {
+			return fmt.Errorf("error removing conflicting objects from the manifest generated for component '%s': %w", component.Name, err)
+		}
+		for _, id := range removed {
+			logger.Warn(emoji.Sprintf(":scissors: Conflict: dropping %s from component '%s' in favor of component '%s'", id, component.Name, drop[id]))
+		}
+	}
+
+	return nil
+}
+
+// normalizeManifests strips the helm '# Source:' comments from the manifests generated for `components`
+// and normalizes them, as requested.
+func normalizeManifests(components []core.Component, normalize bool, stripSourceComments bool) (err error) {
+	for index := range components {
+		component := &components[index]
+		if stripSourceComments {
+			component.Manifest = manifest.StripSourceComments(component.Manifest)
+		}
+		if normalize {
+			if component.Manifest, err = manifest.Normalize(component.Manifest); err != nil {
+				return fmt.Errorf("error normalizing the manifest generated for component '%s': %w", component.Name, err)
+			}
+		}
+	}
 
+	return nil
+}
+
+// checkOutput returns an error if the generated manifests cannot be written as requested by `opts`.
+func checkOutput(startPath string, opts GenerateOptions) error {
+	switch opts.OutputLayout {
+	case "", LayoutComponent, LayoutSingle, LayoutPerResource, LayoutKustomize:
+	default:
+		return fmt.Errorf("unknown output layout '%s', expected %s, %s, %s, or %s", opts.OutputLayout, LayoutComponent, LayoutSingle, LayoutPerResource, LayoutKustomize)
+	}
+
+	switch opts.Validation.Validator {
+	case "", "kubectl", "schema":
+	default:
+		return fmt.Errorf("unknown validator '%s', expected kubectl or schema", opts.Validation.Validator)
+	}
+	if opts.Validation.Validator == "schema" {
+		if err := schemaValidator(opts.Validation).CheckCached(); err != nil {
+			return err
+		}
+	}
+
+	if opts.OutputDir == StdoutOutput {
+		if opts.Validation.Validator != "" {
+			return errors.New("generated manifests written to stdout cannot be validated")
+		}
+		return nil
+	}
+
+	// The manifests in the output directory are replaced on every generate
+	if opts.OutputDir != "" {
+		outputDir, err := filepath.Abs(opts.OutputDir)
+		if err != nil {
+			return err
+		}
+		definitionDir, err := filepath.Abs(startPath)
+		if err != nil {
+			return err
+		}
+		if _, ok := withinDir(definitionDir, outputDir); ok {
+			return fmt.Errorf("output directory '%s' contains the definition and would be replaced", opts.OutputDir)
+		}
+		// Only the generated directory of the definition may be replaced as a whole; directories outside of
+		// the definition must be empty or hold the manifests of a previous generate
+		relative, within := withinDir(outputDir, definitionDir)
+		if within && relative != "generated" && !strings.HasPrefix(relative, "generated"+string(filepath.Separator)) {
+			return fmt.Errorf("output directory '%s' is within the definition and would replace its files; use a directory outside of it, or within generated/", opts.OutputDir)
+		}
+		if !within {
+			if _, err := previouslyGenerated(opts.OutputDir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Generate implements the 'generate' command. It takes a set of environments and options and iterates
+// through the component tree, generating components as it reaches them, and writing all of the generated
+// manifests at the very end.
+func Generate(startPath string, environments []string, opts GenerateOptions) (components []core.Component, err error) {
+	if err = checkOutput(startPath, opts); err != nil {
+		return nil, err
+	}
+
+	components, err = GenerateComponents(startPath, environments)
 	if err != nil {
 		return nil, err
 	}
 
-	environmentName := strings.Join(environments, "-")
-	if len(environmentName) == 0 {
-		environmentName = "common"
+	// Components are kept in walk order, dependencies first, for the manifests to apply in order; the
+	// conflicts are resolved in order of generated files
+	generationPath := generationPath(startPath, environments)
+	sortByWalkOrder(components)
+	byGeneratedFile := sortedByGeneratedFile(generationPath, components)
+	if err = resolveConflicts(byGeneratedFile, opts.Conflicts); err != nil {
+		return nil, err
+	}
+	resolved := map[string]string{}
+	for _, component := range byGeneratedFile {
+		resolved[componentKey(component)] = component.Manifest
+	}
+	for index := range components {
+		components[index].Manifest = resolved[componentKey(components[index])]
+	}
+	if err = normalizeManifests(components, opts.Normalize, opts.StripSourceComments); err != nil {
+		return nil, err
 	}
 
-	generationPath := path.Join(startPath, "generated", environmentName)
+	if opts.OutputDir == StdoutOutput {
+		stdout := opts.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		stream, err := singleStream(components, opts.Normalize)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.WriteString(stdout, stream)
+		return components, err
+	}
 
-	if err = writeGeneratedManifests(generationPath, components); err != nil {
+	if opts.OutputDir != "" {
+		generationPath = opts.OutputDir
+	}
+	owned, err := ownedOutputDir(startPath, generationPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeGeneratedManifests(generationPath, opts.OutputLayout, components, opts.Normalize, owned); err != nil {
 		return nil, err
 	}
 
-	if validate {
-		if err = validateGeneratedManifests(generationPath); err != nil {
+	validation := opts.Validation
+	switch validation.Validator {
+	case "":
+	case "kubectl":
+		if core.Offline.Enabled() {
+			// kubectl fetches the schemas to validate against from the cluster
+			logger.Warn(emoji.Sprintf(":no_entry_sign: Offline: skipping validation of generated manifests in path %s", generationPath))
+		} else if err = validateGeneratedManifests(generationPath, opts.OutputLayout); err != nil {
+			return nil, err
+		}
+	case "schema":
+		if err = validateGeneratedManifestsAgainstSchemas(generationPath, validation); err != nil {
 			return nil, err
 		}
 	}
@@ -105,7 +759,7 @@ func Generate(startPath string, environments []string, validate bool) (component
 }
 
 var generateCmd = &cobra.Command{
-	Use:   "generate <config1> <config2> ... <configN>",
+	Use:   "generate <config1> <config2> ... <configN> [-o <dir>|-] [--output-layout component|single|per-resource|kustomize] [--normalize] [--strip-source-comments]",
 	Short: "Generates Kubernetes resource definitions from deployment definition.",
 	Long: `Generate produces Kubernetes resource manifests from a deployment definition.
 
@@ -113,18 +767,105 @@ Where the generate command takes a list of the configurations that should be use
 definitions for the deployment.  These configurations should be specified in priority order.  For example,
 if you specified "prod azure east", prod's config would be applied first, and azure's config
 would only be applied if they did not conflict with prod. Likewise, east's config would only be applied
-if it did not conflict with prod or azure.`,
+if it did not conflict with prod or azure.
+
+With --validate, the generated manifests are validated with 'kubectl apply --dry-run', which requires
+kubectl and usually a cluster. With --validator schema they are instead validated against the JSON
+schemas of the Kubernetes objects of --kube-version, without kubectl or a cluster. The schemas are
+downloaded from --schema-location (and kept in the install cache), or read from it when it is a local
+directory; with --offline, only cached and local schemas are used.
+
+Objects generated by several components (eg. a CustomResourceDefinition shipped by two charts) are
+reported as conflicts. With --conflicts warn (the default) all of them are written, with error the
+generation fails, and with first-wins only that of the component whose generated file comes first is
+written. Remove objects from the manifest of a component with its 'exclude' selectors.
+
+The manifests are written to generated/<config1>-<config2>-...-<configN>, or to --output-dir (-o), which
+must be empty or only have the manifests of a previous generate replaced; '-o -' writes them to stdout
+instead, as a single stream. --output-layout selects how they are laid out:
+component (the default) writes a <name>.yaml file per component in the directory of its logical path,
+single writes a single manifests.yaml file, per-resource writes a <kind>-<name>.yaml file per object in a
+directory per component, and kustomize writes a file per component along with a kustomization.yaml listing
+the resources of each directory.
+
+With --normalize, the objects of every component are sorted by kind (Namespaces, CustomResourceDefinitions
+and RBAC first, then workloads), apiVersion, namespace, and name, and their keys alphabetically, such that
+the same definition always generates the same bytes. --strip-source-comments removes the '# Source:'
+comments helm adds to the objects it renders.
+
+Config files and static manifests encrypted with sops, either as a whole (eg. config/prod.enc.yaml) or
+with ENC[...] values, are decrypted in memory with the age key of --age-key-file, $SOPS_AGE_KEY_FILE or
+$SOPS_AGE_KEY, or the PGP keys of the gpg keyring. Decryption requires sops.
+
+example:
+
+$ fab generate prod --output-layout kustomize -o ../gitops/clusters/prod
+$ fab generate prod -o - | kubectl diff -f -
+$ fab generate prod --conflicts error
+$ fab generate prod --normalize --strip-source-comments
+$ fab generate prod --validate --validator schema --kube-version 1.18.0
+$ fab generate prod --validate --validator schema --schema-location ./schemas --schema-location default
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := GenerateOptions{
+			Conflicts:           cmd.Flag("conflicts").Value.String(),
+			Normalize:           cmd.Flag("normalize").Value.String() == "true",
+			StripSourceComments: cmd.Flag("strip-source-comments").Value.String() == "true",
+			OutputDir:           cmd.Flag("output-dir").Value.String(),
+			OutputLayout:        cmd.Flag("output-layout").Value.String(),
+		}
+		if opts.OutputDir == StdoutOutput {
+			// Keep stdout for the generated manifests
+			logger.SetStdout(os.Stderr)
+		}
+
 		PrintVersion()
 
-		validation := cmd.Flag("validate").Value.String()
-		_, err := Generate("./", args, validation == "true")
+		// Only generate decrypts encrypted config files and manifests
+		core.Decryption.Enable(true, cmd.Flag("age-key-file").Value.String())
+
+		if cmd.Flag("validate").Value.String() == "true" {
+			opts.Validation.Validator = cmd.Flag("validator").Value.String()
+		}
+		opts.Validation.KubernetesVersion = cmd.Flag("kube-version").Value.String()
+		opts.Validation.SchemaLocations = schemaLocations(generateSchemaLocations)
+		opts.Validation.Strict = cmd.Flag("strict-schemas").Value.String() == "true"
+		opts.Validation.IgnoreMissingSchemas = cmd.Flag("ignore-missing-schemas").Value.String() == "true"
+
+		_, err := Generate("./", args, opts)
 
 		return err
 	},
 }
 
+// generateSchemaLocations are the --schema-location flags of generate.
+var generateSchemaLocations []string
+
+// schemaLocations returns the schema locations, replacing `default` with manifest.DefaultSchemaLocation.
+func schemaLocations(locations []string) []string {
+	resolved := []string{}
+	for _, location := range locations {
+		if location == "default" {
+			location = manifest.DefaultSchemaLocation
+		}
+		resolved = append(resolved, location)
+	}
+
+	return resolved
+}
+
 func init() {
+	generateCmd.PersistentFlags().StringP("output-dir", "o", "", "Directory to write the generated manifests to, replacing those of the previous generate, or - for stdout (default generated/<configs>)")
+	generateCmd.PersistentFlags().String("output-layout", LayoutComponent, "Layout of the generated manifests: component, single, per-resource, or kustomize")
+	generateCmd.PersistentFlags().Bool("normalize", false, "Sort the generated objects by kind and name, and their keys, for output identical from one generate to the next")
+	generateCmd.PersistentFlags().Bool("strip-source-comments", false, "Remove the '# Source:' comments helm adds to the generated manifests")
+	generateCmd.PersistentFlags().String("age-key-file", "", "age key file to decrypt encrypted config files and manifests with (default $SOPS_AGE_KEY_FILE)")
+	generateCmd.PersistentFlags().String("conflicts", ConflictsWarn, "Policy for objects generated by several components: error, warn, or first-wins")
 	generateCmd.PersistentFlags().Bool("validate", false, "Validate generated resource manifest YAML")
+	generateCmd.PersistentFlags().String("validator", "kubectl", "Validator used by --validate: kubectl, or schema to validate against the Kubernetes schemas without a cluster")
+	generateCmd.PersistentFlags().String("kube-version", "master", "Kubernetes version of the schemas to validate against, eg. 1.18.0")
+	generateCmd.PersistentFlags().StringSliceVar(&generateSchemaLocations, "schema-location", []string{}, "Directory, URL or path template of the schemas to validate against, in order; 'default' for the schemas of the Kubernetes versions (default)")
+	generateCmd.PersistentFlags().Bool("strict-schemas", false, "Fail validation of objects with fields not in their schema")
+	generateCmd.PersistentFlags().Bool("ignore-missing-schemas", false, "Skip validation of objects of kinds without a schema, eg. custom resources")
 	rootCmd.AddCommand(generateCmd)
 }
@@ -1,16 +1,24 @@
 package cmd
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
 	"testing"
 
 	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/microsoft/fabrikate/internal/manifest"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerate(t *testing.T) {
 	type args struct {
 		startPath    string
 		environments []string
-		validate     bool
+		opts         GenerateOptions
 	}
 	tests := []struct {
 		name        string
@@ -23,7 +31,7 @@ func TestGenerate(t *testing.T) {
 			args{
 				"../../testdata/generate",
 				[]string{"prod-east", "prod"},
-				false,
+				GenerateOptions{},
 			},
 			map[string]int{
 				"microservices-workload": 0,
@@ -39,7 +47,7 @@ func TestGenerate(t *testing.T) {
 			args{
 				"../../testdata/generate-yaml",
 				[]string{"prod"},
-				false,
+				GenerateOptions{},
 			},
 			map[string]int{
 				"prometheus-grafana": 125,
@@ -54,7 +62,7 @@ func TestGenerate(t *testing.T) {
 			args{
 				"../../testdata/generate-remote-static",
 				[]string{"common"},
-				false,
+				GenerateOptions{},
 			},
 			map[string]int{
 				"keyvault-flexvolume": 5,
@@ -68,7 +76,7 @@ func TestGenerate(t *testing.T) {
 			args{
 				"../../testdata/generate-hooks",
 				[]string{"prod"},
-				false,
+				GenerateOptions{},
 			},
 			map[string]int{
 				"generate-hooks": 103,
@@ -81,7 +89,7 @@ func TestGenerate(t *testing.T) {
 			args{
 				"../../testdata/generate-disabled",
 				[]string{"disabled"},
-				false,
+				GenerateOptions{},
 			},
 			map[string]int{
 				"disabled-stack": 0,
@@ -103,7 +111,7 @@ func TestGenerate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotComponents, err := Generate(tt.args.startPath, tt.args.environments, tt.args.validate)
+			gotComponents, err := Generate(tt.args.startPath, tt.args.environments, tt.args.opts)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Generate() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -113,3 +121,462 @@ func TestGenerate(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateValidatesConfigSchema(t *testing.T) {
+	components, err := GenerateComponents("../../testdata/config-schema", []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(components))
+
+	// additional property, with the config file setting it
+	_, err = GenerateComponents("../../testdata/config-schema", []string{"typo"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "component 'app' at 'app'")
+	assert.Contains(t, err.Error(), "replicaCount: Additional property replicaCount is not allowed (set in ../../testdata/config-schema/config/typo.yaml)")
+
+	// wrong types
+	_, err = GenerateComponents("../../testdata/config-schema", []string{"string"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "replicas: Invalid type. Expected: integer, given: string")
+
+	// global config is not reported as additional properties of the components it is added to
+	components, err = GenerateComponents("../../testdata/config-schema", []string{"global"})
+	assert.Nil(t, err)
+	for _, component := range components {
+		assert.Equal(t, "east", component.Config.Config["region"])
+	}
+
+	// but set for the component itself, it is
+	_, err = GenerateComponents("../../testdata/config-schema", []string{"global", "typo"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "replicaCount: Additional property replicaCount is not allowed")
+	assert.NotContains(t, err.Error(), "region")
+	assert.NotContains(t, err.Error(), "tier")
+
+	// the conventional config/schema.yaml
+	_, err = GenerateComponents("../../testdata/config-schema", []string{"host"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "component 'web' at 'web' does not match schema '../../testdata/config-schema/web/config/schema.yaml'")
+}
+
+func TestGenerateValidatesAgainstSchemas(t *testing.T) {
+	opts := GenerateOptions{Validation: ValidateOptions{Validator: "schema", SchemaLocations: []string{"../../testdata/validate-schema/schemas"}}}
+
+	// the file, object and field of each failure
+	_, err := Generate("../../testdata/validate-schema", []string{"common"}, opts)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "../../testdata/validate-schema/generated/common/broken.yaml: apps/v1 Deployment broken: spec.replicas: Invalid type. Expected: integer, given: string")
+	assert.Contains(t, err.Error(), "example.com/v1 Widget widget: no schema found")
+
+	_, err = Generate("../../testdata/validate-schema", []string{"fixed"}, opts)
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "Deployment broken")
+
+	opts.Validation.IgnoreMissingSchemas = true
+	components, err := Generate("../../testdata/validate-schema", []string{"fixed"}, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(components))
+
+	opts.Validation.Validator = "kubeval"
+	_, err = Generate("../../testdata/validate-schema", []string{"fixed"}, opts)
+	assert.NotNil(t, err)
+}
+
+func TestGenerateConflicts(t *testing.T) {
+	// The CustomResourceDefinition of charts-c is excluded
+	_, err := Generate("../../testdata/conflicts", []string{}, GenerateOptions{Conflicts: ConflictsError})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "rbac.authorization.k8s.io/v1 ClusterRole widget-reader is generated by components 'charts-a' (charts-a), 'charts-b' (charts-b)")
+	assert.Contains(t, err.Error(), "apiextensions.k8s.io/v1 CustomResourceDefinition widgets.example.com is generated by components 'charts-a' (charts-a), 'charts-b' (charts-b)")
+	assert.NotContains(t, err.Error(), "charts-c")
+
+	manifests := func(components []core.Component) map[string]string {
+		byName := map[string]string{}
+		for _, component := range components {
+			byName[component.Name] = component.Manifest
+		}
+		return byName
+	}
+
+	components, err := Generate("../../testdata/conflicts", []string{}, GenerateOptions{Conflicts: ConflictsWarn})
+	assert.Nil(t, err)
+	assert.Contains(t, manifests(components)["charts-b"], "widget-reader")
+	assert.NotContains(t, manifests(components)["charts-c"], "CustomResourceDefinition")
+
+	// The objects of charts-a are kept, those of charts-b dropped, even from a List
+	components, err = Generate("../../testdata/conflicts", []string{}, GenerateOptions{Conflicts: ConflictsFirstWins})
+	assert.Nil(t, err)
+	assert.Contains(t, manifests(components)["charts-a"], "widget-reader")
+	assert.Contains(t, manifests(components)["charts-a"], "CustomResourceDefinition")
+	assert.NotContains(t, manifests(components)["charts-b"], "widget-reader")
+	assert.NotContains(t, manifests(components)["charts-b"], "CustomResourceDefinition")
+	assert.Contains(t, manifests(components)["charts-b"], "name: b")
+
+	_, err = Generate("../../testdata/conflicts", []string{}, GenerateOptions{Conflicts: "last-wins"})
+	assert.NotNil(t, err)
+}
+
+func TestGenerateStdoutDependsOnOrder(t *testing.T) {
+	var stdout bytes.Buffer
+	_, err := Generate("../../testdata/depends-on", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &stdout})
+	assert.Nil(t, err)
+
+	// 'app' depends on 'crds': the CustomResourceDefinition must be applied before the Widget
+	objects, err := manifest.Parse(stdout.String())
+	assert.Nil(t, err)
+	kinds := []string{}
+	for _, object := range objects {
+		kinds = append(kinds, object.Identity().Kind)
+	}
+	assert.Equal(t, []string{"CustomResourceDefinition", "Widget"}, kinds)
+}
+
+func TestGenerateOutputLayouts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	generatedFiles := func(outputDir string) (files []string) {
+		assert.Nil(t, filepath.Walk(outputDir, func(filePath string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && info.Name() != generatedListFilename {
+				relative, _ := filepath.Rel(outputDir, filePath)
+				files = append(files, filepath.ToSlash(relative))
+			}
+			return err
+		}))
+		return files
+	}
+
+	tests := []struct {
+		layout string
+		files  []string
+	}{
+		{LayoutComponent, []string{"app/app.yaml", "app/web.yaml", "layouts.yaml", "monitoring.yaml"}},
+		{LayoutSingle, []string{"manifests.yaml"}},
+		{LayoutPerResource, []string{"app/web/configmap-staging-web.yaml", "app/web/configmap-web-web.yaml", "app/web/deployment-web.yaml", "monitoring/namespace-monitoring.yaml"}},
+		{LayoutKustomize, []string{"app/kustomization.yaml", "app/web.yaml", "kustomization.yaml", "monitoring.yaml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			outputDir := path.Join(dir, tt.layout)
+			_, err := Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: outputDir, OutputLayout: tt.layout})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.files, generatedFiles(outputDir))
+
+			// Every layout generates the same objects
+			objects, err := manifest.ParseDir(outputDir)
+			assert.Nil(t, err)
+			assert.Equal(t, 4, len(objects))
+		})
+	}
+
+	kustomization, err := ioutil.ReadFile(path.Join(dir, LayoutKustomize, "kustomization.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- app\n- monitoring.yaml\n", string(kustomization))
+
+	// Regenerating removes the files of the previous generation
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: path.Join(dir, LayoutKustomize), OutputLayout: LayoutSingle})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifests.yaml"}, generatedFiles(path.Join(dir, LayoutKustomize)))
+
+	// but keeps the other files of the output directory, eg. of a GitOps repository
+	gitops := path.Join(dir, LayoutKustomize)
+	assert.Nil(t, os.MkdirAll(path.Join(gitops, ".git"), 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(gitops, ".git", "HEAD"), []byte("ref: refs/heads/master\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(gitops, "README.md"), []byte("# GitOps\n"), 0644))
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: gitops, OutputLayout: LayoutPerResource})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".git/HEAD", "README.md", "app/web/configmap-staging-web.yaml", "app/web/configmap-web-web.yaml", "app/web/deployment-web.yaml", "monitoring/namespace-monitoring.yaml"}, generatedFiles(gitops))
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: gitops, OutputLayout: LayoutSingle})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".git/HEAD", "README.md", "manifests.yaml"}, generatedFiles(gitops))
+	_, err = os.Stat(path.Join(gitops, "app"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Directories with files generate did not write are left untouched
+	checkout := path.Join(dir, "checkout")
+	assert.Nil(t, os.MkdirAll(path.Join(checkout, ".git"), 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(checkout, "app.yaml"), []byte("kind: ConfigMap\n"), 0644))
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: checkout, OutputLayout: LayoutSingle})
+	assert.EqualError(t, err, fmt.Sprintf("output directory '%s' is not empty and was not written by generate; use an empty directory", checkout))
+	assert.Equal(t, []string{"app.yaml"}, generatedFiles(checkout))
+
+	var stdout bytes.Buffer
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &stdout})
+	assert.Nil(t, err)
+	single, err := ioutil.ReadFile(path.Join(dir, LayoutSingle, singleManifestFilename))
+	assert.Nil(t, err)
+	assert.Equal(t, string(single), stdout.String())
+
+	// Invalid output options fail before generating
+	for _, opts := range []GenerateOptions{
+		{OutputDir: dir, OutputLayout: "flat"},
+		{OutputDir: StdoutOutput, Validation: ValidateOptions{Validator: "schema"}},
+		{OutputDir: "../../testdata"},
+		{OutputDir: "../../testdata/layouts"},
+		{OutputDir: "../../testdata/layouts/app"},
+	} {
+		_, err = Generate("../../testdata/layouts", []string{}, opts)
+		assert.NotNil(t, err, opts)
+	}
+
+	// The config files within the definition are left untouched
+	_, err = Generate("../../testdata/global", []string{}, GenerateOptions{OutputDir: "../../testdata/global/config"})
+	assert.EqualError(t, err, "output directory '../../testdata/global/config' is within the definition and would replace its files; use a directory outside of it, or within generated/")
+	_, err = os.Stat("../../testdata/global/config/common.yaml")
+	assert.Nil(t, err)
+
+	// but the generated directory of the definition may be replaced
+	outputDir := "../../testdata/layouts/generated/single"
+	defer os.RemoveAll("../../testdata/layouts/generated")
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: outputDir, OutputLayout: LayoutSingle})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifests.yaml"}, generatedFiles(outputDir))
+}
+
+func TestGenerateNormalize(t *testing.T) {
+	var stdout bytes.Buffer
+	_, err := Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &stdout, Normalize: true, StripSourceComments: true})
+	assert.Nil(t, err)
+
+	// The objects of all components are sorted together: the Namespace of 'monitoring' comes first
+	assert.Equal(t, `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: monitoring
+---
+apiVersion: v1
+data:
+  color: green
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: staging
+---
+apiVersion: v1
+data:
+  color: blue
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: web
+spec:
+  replicas: 2
+`, stdout.String())
+
+	// Generating again gives the same bytes
+	var again bytes.Buffer
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &again, Normalize: true, StripSourceComments: true})
+	assert.Nil(t, err)
+	assert.Equal(t, stdout.String(), again.String())
+
+	// as in the single layout
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	outputDir := path.Join(dir, "normalized")
+	_, err = Generate("../../testdata/layouts", []string{}, GenerateOptions{OutputDir: outputDir, OutputLayout: LayoutSingle, Normalize: true, StripSourceComments: true})
+	assert.Nil(t, err)
+	single, err := ioutil.ReadFile(path.Join(outputDir, "manifests.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, stdout.String(), string(single))
+}
+
+func TestGenerateCommonMetadata(t *testing.T) {
+	components, err := Generate("../../testdata/common-metadata", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.Nil(t, err)
+
+	objects := map[string]map[string]interface{}{}
+	for _, component := range components {
+		componentObjects, err := manifest.Parse(component.Manifest)
+		assert.Nil(t, err)
+		for _, object := range componentObjects {
+			objects[object.Identity().Kind] = object
+		}
+	}
+
+	labels := func(object map[string]interface{}, keys ...string) interface{} {
+		var value interface{} = object
+		for _, key := range keys {
+			value = value.(map[string]interface{})[key]
+		}
+		return value
+	}
+
+	// Labels are inherited down the tree, and those set by objects or closer to them win
+	assert.Equal(t, map[string]interface{}{"team": "web", "cost-center": "1234", "fabrikate.io/component": "app"}, labels(objects["Deployment"], "metadata", "labels"))
+	assert.Equal(t, map[string]interface{}{"team": "platform", "cost-center": "1234", "fabrikate.io/component": "app"}, labels(objects["Service"], "metadata", "labels"))
+	assert.Equal(t, map[string]interface{}{"team": "observability", "cost-center": "1234", "fabrikate.io/component": "dashboard"}, labels(objects["ConfigMap"], "metadata", "labels"))
+	assert.Equal(t, map[string]interface{}{"example.com/owner": "platform@example.com"}, labels(objects["ConfigMap"], "metadata", "annotations"))
+
+	// app adds them to its templates and selectors
+	assert.Equal(t, map[string]interface{}{"app": "web", "team": "platform", "cost-center": "1234", "fabrikate.io/component": "app"}, labels(objects["Deployment"], "spec", "template", "metadata", "labels"))
+	assert.Equal(t, map[string]interface{}{"example.com/owner": "platform@example.com"}, labels(objects["Deployment"], "spec", "template", "metadata", "annotations"))
+	assert.Equal(t, map[string]interface{}{"app": "web", "team": "platform", "cost-center": "1234", "fabrikate.io/component": "app"}, labels(objects["Deployment"], "spec", "selector", "matchLabels"))
+	assert.Equal(t, map[string]interface{}{"app": "web", "team": "platform", "cost-center": "1234", "fabrikate.io/component": "app"}, labels(objects["Service"], "spec", "selector"))
+}
+
+func TestGenerateInjectNamespace(t *testing.T) {
+	components, err := Generate("../../testdata/namespaces", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.Nil(t, err)
+
+	namespaces := map[string]string{}
+	for _, component := range components {
+		objects, err := manifest.Parse(component.Manifest)
+		assert.Nil(t, err)
+		for _, object := range objects {
+			namespaces[object.Identity().Kind] = object.Identity().Namespace
+		}
+	}
+
+	// Namespaced objects of static components get the namespace, those of cluster scoped kinds do not
+	assert.Equal(t, map[string]string{
+		"Namespace":      "",
+		"ServiceAccount": "operator",
+		"ClusterRole":    "",
+		"Deployment":     "operator-system",
+		"ClusterIssuer":  "",
+	}, namespaces)
+}
+
+// fakeSops puts a sops on the PATH which "decrypts" ENC[...,data:<value>,...] values to <value> and drops
+// the sops metadata, failing without an age key file. Returns a func restoring the PATH.
+func fakeSops(t *testing.T, dir string) func() {
+	script := `#!/bin/sh
+[ -n "$SOPS_AGE_KEY_FILE" ] || { echo "no age key" >&2; exit 1; }
+for last; do true; done
+sed -e '/^sops:/,$d' -e 's/ENC\[[^,]*,data:\([^,]*\),[^]]*\]/\1/g' "$last"
+`
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, core.SopsExecutable), []byte(script), 0755))
+
+	originalPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+originalPath)
+	return func() { os.Setenv("PATH", originalPath) }
+}
+
+func TestGenerateDecryptsSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer fakeSops(t, dir)()
+
+	generated := func() (config map[string]interface{}, manifest string, skipped []string) {
+		components, err := Generate("../../testdata/secrets", []string{"prod"}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+		assert.Nil(t, err)
+		for _, component := range components {
+			if component.Name == "database" {
+				return component.Config.Config, component.Manifest, component.SkippedManifests
+			}
+		}
+		return nil, "", nil
+	}
+
+	// Without decryption, encrypted values are left as is, and encrypted files skipped
+	config, manifest, skipped := generated()
+	assert.Equal(t, "ENC[AES256_GCM,data:hunter2,iv:aXY=,tag:dGFn,type:str]", config["password"])
+	assert.Nil(t, config["replicationPassword"])
+	assert.NotContains(t, manifest, "kind: Secret")
+	assert.Equal(t, []string{"../../testdata/secrets/database/secret.enc.yaml"}, skipped)
+
+	core.Decryption.Enable(true, path.Join(dir, "age.key"))
+	defer core.Decryption.Enable(false, "")
+	config, manifest, skipped = generated()
+	assert.Empty(t, skipped)
+	assert.Equal(t, "admin", config["user"])
+	assert.Equal(t, "hunter2", config["password"])
+	assert.Equal(t, "s3cr3t", config["replicationPassword"])
+	assert.Contains(t, manifest, "kind: Secret")
+	assert.Contains(t, manifest, "password: hunter2")
+	assert.NotContains(t, manifest, "sops")
+
+	// Decryption fails without a key
+	core.Decryption.Enable(true, "")
+	defer os.Setenv("SOPS_AGE_KEY_FILE", os.Getenv("SOPS_AGE_KEY_FILE"))
+	os.Unsetenv("SOPS_AGE_KEY_FILE")
+	_, err = Generate("../../testdata/secrets", []string{"prod"}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.NotNil(t, err)
+}
+
+func TestGenerateInterpolatesConfig(t *testing.T) {
+	originalClusterName, clusterNameSet := os.LookupEnv("CLUSTER_NAME")
+	os.Setenv("CLUSTER_NAME", "west")
+	defer func() {
+		if clusterNameSet {
+			os.Setenv("CLUSTER_NAME", originalClusterName)
+		} else {
+			os.Unsetenv("CLUSTER_NAME")
+		}
+	}()
+
+	components, err := Generate("../../testdata/interpolation", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.Nil(t, err)
+
+	configs := map[string]map[string]interface{}{}
+	for _, component := range components {
+		configs[component.Name] = component.Config.Config
+	}
+
+	assert.Equal(t, "west", configs["interpolation"]["cluster"])
+	assert.Equal(t, "west.example.com", configs["ingress"]["domain"])
+	assert.Equal(t, map[string]interface{}{
+		"host":      "app.west.example.com",
+		"namespace": "web",
+		"tls":       map[string]interface{}{"enabled": true},
+		"literal":   "${env:HOME}",
+	}, configs["app"])
+
+	// Unset environment variables fail the generation
+	os.Unsetenv("CLUSTER_NAME")
+	_, err = Generate("../../testdata/interpolation", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "CLUSTER_NAME")
+}
+
+func TestGenerateGlobalConfig(t *testing.T) {
+	components, err := Generate("../../testdata/global", []string{}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.Nil(t, err)
+
+	configs := map[string]map[string]interface{}{}
+	for _, component := range components {
+		configs[component.Name] = component.Config.Config
+	}
+
+	// Global config applies to the whole subtree; lower levels override it
+	assert.Equal(t, map[string]interface{}{
+		"imageRegistry": "registry.example.com",
+		"image":         map[string]interface{}{"pullPolicy": "Always"},
+	}, configs["global"])
+	assert.Equal(t, map[string]interface{}{
+		"imageRegistry": "registry.example.com",
+		"image":         map[string]interface{}{"pullPolicy": "IfNotPresent"},
+	}, configs["cache"])
+	assert.Equal(t, map[string]interface{}{
+		"imageRegistry": "private.example.com",
+		"image":         map[string]interface{}{"pullPolicy": "Always", "tag": "v1"},
+	}, configs["app"])
+	assert.Equal(t, map[string]interface{}{
+		"imageRegistry": "private.example.com",
+		"image":         map[string]interface{}{"pullPolicy": "Always"},
+	}, configs["web"])
+}
+
+func TestGenerateExtendedEnvironments(t *testing.T) {
+	components, err := Generate("../../testdata/extends", []string{"prod-east"}, GenerateOptions{OutputDir: StdoutOutput, Stdout: &bytes.Buffer{}})
+	assert.Nil(t, err)
+
+	configs := map[string]map[string]interface{}{}
+	for _, component := range components {
+		configs[component.Name] = component.Config.Config
+	}
+
+	// The environments extended by the root component are loaded for the whole tree
+	assert.Equal(t, "prod-east", configs["extends"]["region"])
+	assert.Equal(t, "production", configs["extends"]["tier"])
+	assert.Equal(t, 3, configs["app"]["replicas"])
+}
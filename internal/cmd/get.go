@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/spf13/cobra"
+	"github.com/timfpark/yaml"
+)
+
+// Get implements the 'get' command. It returns the config value at `path` in the config file of
+// `environment` for the component (or a subcomponent of it) in the current directory. If `effective`
+// is set, it instead returns the value merged from the configs of the comma separated `environment`s
+// and `common` for the (sub)component, as generate uses it.
+func Get(environment string, subcomponent string, path string, effective bool) (value interface{}, err error) {
+	if effective {
+		environments := []string{}
+		for _, env := range splitEnvironments(environment) {
+			if env != "common" {
+				environments = append(environments, env)
+			}
+		}
+
+		explanation, err := Explain("./", environments, subcomponent, path)
+		if err != nil {
+			return nil, err
+		}
+		if !explanation.Set {
+			return nil, fmt.Errorf("%s is not set for component '%s'", path, explanation.Component)
+		}
+
+		return explanation.Value, nil
+	}
+
+	subcomponentPath := []string{}
+	if len(subcomponent) > 0 {
+		subcomponentPath = strings.Split(subcomponent, ".")
+	}
+
+	pathParts, err := SplitPathParts(path)
+	if err != nil {
+		return nil, err
+	}
+
+	componentConfig := core.NewComponentConfig(".")
+	if err := componentConfig.Load(environment); err != nil {
+		return nil, err
+	}
+
+	notSetError := fmt.Errorf("%s is not set in the '%s' config", path, environment)
+	if !componentConfig.HasSubcomponentConfig(subcomponentPath) {
+		return nil, notSetError
+	}
+
+	subcomponentConfig := componentConfig.GetSubcomponentConfig(subcomponentPath)
+	if value, ok := subcomponentConfig.GetComponentConfig(pathParts); ok {
+		return value, nil
+	}
+
+	return nil, notSetError
+}
+
+// printConfigValue writes a config value to `out`; scalars as is, maps and lists as YAML.
+func printConfigValue(out io.Writer, value interface{}) error {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		marshaled, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(marshaled)
+		return err
+	}
+
+	_, err := fmt.Fprintln(out, value)
+	return err
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get [--environment environment] [--subcomponent subcomponent] [--effective] <path>",
+	Short: "Gets a config value for a component for a particular config environment in the Fabrikate definition.",
+	Long: `Gets a config value for a component for a particular config environment in the Fabrikate definition.
+Scalar values are printed as is; maps and lists as YAML.
+eg.
+$ fab get --environment prod data.replicas
+
+Prints the value of 'data.replicas' in the 'prod' config for the current component.
+
+$ fab get --subcomponent "myapp.mysubcomponent" data.replicas
+
+Prints the value of 'data.replicas' in the 'common' config (the default) for the subcomponent 'mysubcomponent'
+of the subcomponent 'myapp'.
+
+$ fab get --environment prod,east --subcomponent "myapp.mysubcomponent" --effective data.replicas
+
+Prints the value of 'data.replicas' for the subcomponent 'mysubcomponent' of the subcomponent 'myapp' as merged
+from the 'prod', 'east', and 'common' configs of the component tree, as generate uses it.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("'get' takes a single config path")
+		}
+
+		effective := cmd.Flag("effective").Value.String() == "true"
+		value, err := Get(cmd.Flag("environment").Value.String(), cmd.Flag("subcomponent").Value.String(), args[0], effective)
+		if err != nil {
+			return err
+		}
+
+		return printConfigValue(os.Stdout, value)
+	},
+}
+
+func init() {
+	getCmd.PersistentFlags().String("environment", "common", "Environment to get the configuration of; a comma separated list with --effective")
+	getCmd.PersistentFlags().String("subcomponent", "", "Subcomponent to get the configuration of")
+	getCmd.PersistentFlags().Bool("effective", false, "Get the value merged from the configs of the whole component tree")
+	rootCmd.AddCommand(getCmd)
+}
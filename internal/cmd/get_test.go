@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	// This test changes the cwd. Must change back so any tests following don't break
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer func() {
+		_ = os.Chdir(cwd)
+	}()
+
+	err = os.Chdir("../../testdata/explain")
+	assert.Nil(t, err)
+
+	// value of a single config file
+	value, err := Get("common", "app.web", "data.replicas", false)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, value)
+
+	value, err = Get("prod", "app.web", "data", false)
+	assert.Nil(t, err)
+	assert.EqualValues(t, map[string]interface{}{"replicas": 3}, value)
+
+	_, err = Get("prod", "app.web", "hosts", false)
+	assert.NotNil(t, err)
+
+	_, err = Get("prod", "app.db", "data.replicas", false)
+	assert.NotNil(t, err)
+
+	// merged value of the component tree
+	value, err = Get("prod", "app.web", "data.replicas", true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, value)
+
+	value, err = Get("common", "app.web", "data.replicas", true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, value)
+
+	value, err = Get("prod", "app.web", "image", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "nginx", value)
+
+	_, err = Get("prod", "app", "data.replicas", true)
+	assert.NotNil(t, err)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/kyokomi/emoji"
 	"github.com/microsoft/fabrikate/internal/core"
@@ -13,8 +14,10 @@ import (
 )
 
 // Install implements the 'install' command.  It installs the component at the given path and all of
-// its subcomponents by iterating the component subtree.
-func Install(path string) (err error) {
+// its subcomponents by iterating the component subtree. Sources are pinned to those recorded in the
+// lock file at the given path unless `update` is true; the lock file is rewritten with the sources
+// resolved during the install.
+func Install(path string, update bool) (err error) {
 	// Make sure host system contains all utils needed by Fabrikate
 	requiredSystemTools := []string{"git", "helm", "sh", "curl"}
 	for _, tool := range requiredSystemTools {
@@ -25,6 +28,14 @@ func Install(path string) (err error) {
 		logger.Info(emoji.Sprintf(":mag: Using %s: %s", tool, path))
 	}
 
+	lockPath := filepath.Join(path, core.LockFilename)
+	lockFile, err := core.LoadLockFile(lockPath)
+	if err != nil {
+		return err
+	}
+	core.InstallLock.Reset(lockFile, update)
+	core.Offline.Reset()
+
 	rootInit := func(startingPath string, environments []string, c core.Component) (component core.Component, err error) {
 		return c.InstallRoot(startingPath, environments)
 	}
@@ -32,13 +43,9 @@ func Install(path string) (err error) {
 	results := core.WalkComponentTree(path, []string{}, func(path string, component *core.Component) (err error) {
 		logger.Info(emoji.Sprintf(":point_right: Starting install for component: %s", component.Name))
 
-		var generator core.Generator
-
-		switch component.ComponentType {
-		case "helm":
-			generator = &generators.HelmGenerator{}
-		case "static":
-			generator = &generators.StaticGenerator{}
+		generator, err := generators.Get(component.ComponentType)
+		if err != nil {
+			return err
 		}
 
 		// Load access tokens and add them to the global token list. Do not overwrite if already present
@@ -62,6 +69,10 @@ func Install(path string) (err error) {
 	}, rootInit)
 
 	components, err := core.SynchronizeWalkResult(results)
+	// Missing offline sources are the likely cause of any other install error
+	if offlineErr := core.Offline.Err(); offlineErr != nil {
+		return offlineErr
+	}
 	if err != nil {
 		return err
 	}
@@ -69,6 +80,13 @@ func Install(path string) (err error) {
 	for _, component := range components {
 		logger.Info(emoji.Sprintf(":white_check_mark: Installed successfully: %s", component.Name))
 	}
+
+	// An offline install cannot resolve newer sources; leave the lock file untouched
+	if !core.Offline.Enabled() {
+		if err = core.InstallLock.Resolved().Write(lockPath); err != nil {
+			return err
+		}
+	}
 	logger.Info(emoji.Sprintf(":raised_hands: Finished install"))
 
 	return err
@@ -79,7 +97,14 @@ var installCmd = &cobra.Command{
 	Short: "Installs all of the remote components specified in the current deployment tree locally",
 	Long: `Installs all of the remote components specified in the current deployment tree locally, iterating the
 component subtree from the current directory to do so.  Required to be executed before generate (if needed), such
-that Fabrikate has all of the dependencies locally to use to generate the resource manifests.`,
+that Fabrikate has all of the dependencies locally to use to generate the resource manifests.
+
+The git commit, helm chart version and digest, and http manifest digest every component resolved to are recorded
+in fab.lock next to the root component. Subsequent installs use the sources recorded in fab.lock; pass --update
+to resolve the latest sources and refresh fab.lock.
+
+With --offline, every git, helm, and http source is satisfied from the install cache or an already installed
+components/ or helm_repos/ directory; install fails with a list of every source found in neither.`,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		PrintVersion()
 
@@ -93,10 +118,13 @@ that Fabrikate has all of the dependencies locally to use to generate the resour
 			return errors.New("install takes zero or one arguments: the path to the root of the definition tree (defaults to current directory)")
 		}
 
-		return Install(path)
+		update := cmd.Flag("update").Value.String()
+
+		return Install(path, update == "true")
 	},
 }
 
 func init() {
+	installCmd.PersistentFlags().Bool("update", false, "Ignore the sources pinned in fab.lock and update it with the latest resolved sources")
 	rootCmd.AddCommand(installCmd)
 }
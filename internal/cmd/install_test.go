@@ -56,7 +56,7 @@ func TestInstall(t *testing.T) {
 		}()
 
 		t.Run(tt.name, func(t *testing.T) {
-			if err := Install(tt.args.path); (err != nil) != tt.wantErr {
+			if err := Install(tt.args.path, false); (err != nil) != tt.wantErr {
 				t.Errorf("Install() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
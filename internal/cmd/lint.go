@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/lint"
+	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/microsoft/fabrikate/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+// lintConfigFilename is the name of the lint config at the root of a definition.
+const lintConfigFilename = "lint.yaml"
+
+// lintRules returns the rules to lint the definition at `startPath` with: the built-in rules and
+// those of its lint.yaml and of `rulesFiles`, less those they disable.
+func lintRules(startPath string, rulesFiles []string) (rules []lint.Rule, err error) {
+	configPaths := []string{}
+	if _, err := os.Stat(path.Join(startPath, lintConfigFilename)); err == nil {
+		configPaths = append(configPaths, path.Join(startPath, lintConfigFilename))
+	}
+	configPaths = append(configPaths, rulesFiles...)
+
+	configs := []lint.Config{}
+	for _, configPath := range configPaths {
+		logger.Info(emoji.Sprintf(":page_facing_up: Loading lint rules from %s", configPath))
+		config, err := lint.LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+
+	return lint.Rules(configs...)
+}
+
+// Lint implements the 'lint' command. It generates the component tree at `startPath` with the config
+// of the given environments and checks the generated objects against the built-in rules and the custom
+// rules of the definition. Returns the rules checked and their findings.
+func Lint(startPath string, environments []string, rulesFiles []string) (rules []lint.Rule, findings []lint.Finding, err error) {
+	rules, err = lintRules(startPath, rulesFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	components, err := GenerateComponents(startPath, environments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Objects generated by several components are reported on all but the first one, in order of files
+	generationPath := generationPath(startPath, environments)
+	sortByGeneratedFile(generationPath, components)
+
+	targets := []lint.Target{}
+	skipped := []lint.Finding{}
+	for _, component := range components {
+		for _, skippedManifest := range component.SkippedManifests {
+			skipped = append(skipped, lint.EncryptedManifestFinding(component.Name, skippedManifest))
+		}
+
+		objects, err := manifest.Parse(component.Manifest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing manifests generated for component '%s': %w", component.Name, err)
+		}
+
+		for _, object := range objects {
+			targets = append(targets, lint.Target{Component: component.Name, File: generatedFilePath(generationPath, component), Object: object})
+		}
+	}
+
+	logger.Info(emoji.Sprintf(":microscope: Linting %d generated objects against %d rules", len(targets), len(rules)))
+	return rules, append(lint.Run(rules, targets), skipped...), nil
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <config1> <config2> ... <configN> [--format text|json|sarif] [--output <file>] [--rules <file>]",
+	Short: "Checks the resource manifests generated from the deployment definition against policy rules.",
+	Long: `Checks the Kubernetes objects generated from the deployment definition against policy rules.
+
+The configs are merged in priority order as for generate. The built-in rules require that container
+images are pinned (no-latest-tag), containers set cpu and memory limits (resource-limits), containers do
+not run privileged (no-privileged), namespaced objects set their namespace (namespace-required), and no two
+components generate the same object (no-duplicates).
+
+Built-in rules are disabled, and custom rules added, in lint.yaml at the root of the definition and in the
+files passed with --rules. Findings are reported as text, JSON, or SARIF, and lint fails if any of them is
+an error.
+
+example:
+
+$ fab lint prod east
+$ fab lint prod --format sarif --output lint.sarif
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rules, findings, err := Lint("./", args, lintRulesFiles)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if output := cmd.Flag("output").Value.String(); output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+
+		if err := lint.WriteReport(out, cmd.Flag("format").Value.String(), rules, findings); err != nil {
+			return err
+		}
+
+		if lint.HasErrors(findings) {
+			return errors.New("generated manifests violate lint rules")
+		}
+
+		return nil
+	},
+}
+
+// lintRulesFiles are the --rules flags of lint.
+var lintRulesFiles []string
+
+func init() {
+	lintCmd.PersistentFlags().String("format", lint.FormatText, "Format of the report: text, json, or sarif")
+	lintCmd.PersistentFlags().String("output", "", "Write the report to a file rather than stdout")
+	lintCmd.PersistentFlags().StringSliceVar(&lintRulesFiles, "rules", []string{}, "Additional YAML file of lint rules")
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/microsoft/fabrikate/internal/lint"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	rules, findings, err := Lint("../../testdata/lint", []string{}, []string{"../../testdata/lint/extra-rules.yaml"})
+	assert.Nil(t, err)
+	assert.True(t, lint.HasErrors(findings))
+
+	ruleIDs := []string{}
+	for _, rule := range rules {
+		ruleIDs = append(ruleIDs, rule.ID())
+	}
+	assert.Equal(t, []string{"no-latest-tag", "resource-limits", "namespace-required", "no-duplicates", "team-label", "approved-registry", "blue-settings"}, ruleIDs)
+
+	found := []string{}
+	for _, finding := range findings {
+		found = append(found, finding.String())
+	}
+	assert.Equal(t, []string{
+		"error no-latest-tag: ../../testdata/lint/generated/common/app.yaml: apps/v1 Deployment app/app: spec.template.spec.containers[0].image: image 'registry.example.com:5000/app:latest' uses the latest tag",
+		"error resource-limits: ../../testdata/lint/generated/common/app.yaml: apps/v1 Deployment app/app: spec.template.spec.containers[0].resources.limits: container 'app' sets no memory limit",
+		"error no-latest-tag: ../../testdata/lint/generated/common/app.yaml: apps/v1 Deployment app/app: spec.template.spec.initContainers[0].image: image 'busybox' has no tag",
+		"warning team-label: ../../testdata/lint/generated/common/shared.yaml: apps/v1 Deployment worker: metadata.labels.team: Deployments are labelled with their team: not set",
+		"error namespace-required: ../../testdata/lint/generated/common/shared.yaml: apps/v1 Deployment worker: metadata.namespace: Deployment has no namespace",
+		"error no-duplicates: ../../testdata/lint/generated/common/shared.yaml: v1 ConfigMap app/settings: object is also generated by component 'app' in ../../testdata/lint/generated/common/app.yaml",
+		"error blue-settings: ../../testdata/lint/generated/common/shared.yaml: v1 ConfigMap app/settings: data.color: Settings are blue: \"green\", expected one of [\"blue\"]",
+	}, found)
+
+	_, _, err = Lint("../../testdata/lint", []string{}, []string{"../../testdata/lint/missing.yaml"})
+	assert.NotNil(t, err)
+}
+
+func TestLintEncryptedManifests(t *testing.T) {
+	_, findings, err := Lint("../../testdata/secrets", []string{"prod"}, []string{})
+	assert.Nil(t, err)
+
+	found := []string{}
+	for _, finding := range findings {
+		if finding.RuleID == lint.EncryptedManifestID {
+			found = append(found, finding.String())
+		}
+	}
+	assert.Equal(t, []string{
+		"warning encrypted-manifest: ../../testdata/secrets/database/secret.enc.yaml: encrypted manifest is not linted; only generate decrypts manifests",
+	}, found)
+}
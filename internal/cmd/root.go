@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/microsoft/fabrikate/internal/helm"
 	"github.com/microsoft/fabrikate/internal/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -26,6 +29,9 @@ var rootCmd = &cobra.Command{
 			logger.SetLevelInfo()
 		}
 
+		cache.SetDir(cmd.Flag("cache-dir").Value.String())
+		core.Offline.Enable(cmd.Flag("offline").Value.String() == "true")
+
 		return nil
 	},
 }
@@ -33,7 +39,11 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if cleanupErr := helm.Cleanup(); cleanupErr != nil {
+		logger.Warn(fmt.Sprintf("Unable to remove the helm repository config and cache of this run: %s", cleanupErr))
+	}
+	if err != nil {
 		logger.Error(err)
 		os.Exit(1)
 	}
@@ -43,6 +53,8 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Use verbose output logs")
+	rootCmd.PersistentFlags().String("cache-dir", "", "Directory of the install cache (default $XDG_CACHE_HOME/fabrikate)")
+	rootCmd.PersistentFlags().Bool("offline", false, "Never access the network; install only from the install cache or previously installed components")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -1,14 +1,18 @@
 package cmd
 
 import (
-	"encoding/csv"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/kyokomi/emoji"
 	"github.com/microsoft/fabrikate/internal/core"
-	"github.com/microsoft/fabrikate/util"
+	"github.com/microsoft/fabrikate/internal/generators"
+	"github.com/microsoft/fabrikate/internal/logger"
 	"github.com/spf13/cobra"
 	"github.com/timfpark/yaml"
 )
@@ -43,37 +47,53 @@ func SplitPathValuePairs(pathValuePairStrings []string) (pathValuePairs []core.P
 
 // SplitPathParts splits path string at . while ignoring string literals enclosed in quotes (".") and returns an array //
 func SplitPathParts(path string) (pathParts []string, err error) {
+	return core.SplitConfigPath(path)
+}
 
-	csv := csv.NewReader(strings.NewReader(path))
-
-	// Comma is the field delimiter. Dot (.) will be the value for config key
-	csv.Comma = '.'
-
-	// setting it to true, a quote may appear in an unquoted field and a non-doubled quote may appear in a quoted field.
-	csv.LazyQuotes = true
+// integerValue matches the config values which are set as integers; numbers with leading zeros are kept as strings.
+var integerValue = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+
+// ParseValue parses a config value given on the command line into an integer, a boolean, or null;
+// any other value is kept as a string.
+func ParseValue(value string) interface{} {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "~":
+		return nil
+	}
 
-	// FieldsPerRecord is the number of expected fields per record.
-	// > 0: Read requires each record to have the given number of fields.
-	// == 0, Read sets it to the number of fields in the first record, so that future records must have the same field count.
-	// < 0, no check is made and config key may have a variable number of fields.
-	csv.FieldsPerRecord = -1
+	if integerValue.MatchString(value) {
+		if integer, err := strconv.Atoi(value); err == nil {
+			return integer
+		}
+	}
 
-	// Read parts and the error
-	parts, err := csv.Read()
+	return value
+}
 
-	// return err and empty parts
-	if err != nil {
-		return nil, err
+// fileValuePairs returns the path value pairs of the leaves of the map `content`, read from a --file.
+// Lists are set as a whole.
+func fileValuePairs(content map[string]interface{}, parentPath []string) (pathValuePairs []core.PathValuePair) {
+	for key, value := range content {
+		path := append(append([]string{}, parentPath...), key)
+		if nestedMap, isMap := value.(map[string]interface{}); isMap && len(nestedMap) > 0 {
+			pathValuePairs = append(pathValuePairs, fileValuePairs(nestedMap, path)...)
+		} else {
+			pathValuePairs = append(pathValuePairs, core.PathValuePair{Path: path, Value: value})
+		}
 	}
 
-	// return key parts
-	return parts, nil
+	return pathValuePairs
 }
 
 // Set implements the 'set' command. It takes an environment, a set of config path / value strings (and a subcomponent if the config
 // should be set on a subcomponent versus the component itself) and sets the config in the appropriate config file,
-// writing the result out to disk at the end.
-func Set(environment string, subcomponent string, pathValuePairStrings []string, noNewConfigKeys bool, inputFile string) (err error) {
+// writing the result out to disk at the end. Values are parsed into integers, booleans, and null (see ParseValue) unless
+// `stringValues` is set.
+func Set(environment string, subcomponent string, pathValuePairStrings []string, noNewConfigKeys bool, inputFile string, stringValues bool) (err error) {
 
 	subcomponentPath := []string{}
 	if len(subcomponent) > 0 {
@@ -82,8 +102,8 @@ func Set(environment string, subcomponent string, pathValuePairStrings []string,
 
 	componentConfig := core.NewComponentConfig(".")
 
-	// Load input file if provided
-	inputFileValuePairList := []string{}
+	// Load input file if provided; its values keep their YAML types
+	pathValuePairs := []core.PathValuePair{}
 	if inputFile != "" {
 		bytes, err := ioutil.ReadFile(inputFile)
 		if err != nil {
@@ -95,24 +115,22 @@ func Set(environment string, subcomponent string, pathValuePairStrings []string,
 			return err
 		}
 
-		// Flatten the map
-		flattenedInputFileContentMap := util.FlattenMap(yamlContent, ".", []string{})
-
-		// Append all key/value in map to the flattened list
-		for k, v := range flattenedInputFileContentMap {
-			// Join to PathValue strings with "="
-			valueAsString := fmt.Sprintf("%v", v)
-			joined := strings.Join([]string{k, valueAsString}, "=")
-			inputFileValuePairList = append(inputFileValuePairList, joined)
-		}
+		pathValuePairs = fileValuePairs(yamlContent, []string{})
 	}
 
-	pathValuePairs, err := SplitPathValuePairs(append(inputFileValuePairList, pathValuePairStrings...))
+	argumentPathValuePairs, err := SplitPathValuePairs(pathValuePairStrings)
 
 	if err != nil {
 		return err
 	}
 
+	for _, pathValue := range argumentPathValuePairs {
+		if !stringValues {
+			pathValue.Value = ParseValue(pathValue.Value.(string))
+		}
+		pathValuePairs = append(pathValuePairs, pathValue)
+	}
+
 	if err := componentConfig.Load(environment); err != nil {
 		return err
 	}
@@ -132,16 +150,65 @@ func Set(environment string, subcomponent string, pathValuePairStrings []string,
 			}
 		}
 
-		componentConfig.SetConfig(subcomponentPath, pathValue.Path, pathValue.Value)
+		if err := componentConfig.SetConfig(subcomponentPath, pathValue.Path, pathValue.Value); err != nil {
+			return err
+		}
+	}
+
+	// Validate the config as merged with the written config file, restoring the previous file if it does not match its schema
+	configPath := componentConfig.GetPath(environment)
+	previousConfig, readErr := ioutil.ReadFile(configPath)
+	if err := componentConfig.Write(environment); err != nil {
+		return err
+	}
+
+	if err := validateConfig(environment, subcomponentPath); err != nil {
+		if readErr == nil {
+			_ = ioutil.WriteFile(configPath, previousConfig, 0644)
+		} else {
+			_ = os.Remove(configPath)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// validateConfig validates the config of the (sub)component at `subcomponentPath` of the component in the
+// current directory, merged from the configs of `environment` and `common`, against its schemas. Components
+// which cannot be loaded, eg. because they are not installed, are not validated.
+func validateConfig(environment string, subcomponentPath []string) error {
+	_, yamlErr := os.Stat("component.yaml")
+	_, jsonErr := os.Stat("component.json")
+	if yamlErr != nil && jsonErr != nil {
+		return nil
+	}
+
+	environments := []string{}
+	if environment != "common" {
+		environments = append(environments, environment)
 	}
 
-	return componentConfig.Write(environment)
+	component, err := findComponent("./", environments, subcomponentPath)
+	if err != nil {
+		logger.Warn(emoji.Sprintf(":question: Unable to validate config against its schema: %s", err))
+		return nil
+	}
+
+	generator, err := generators.Get(component.ComponentType)
+	if err != nil {
+		logger.Warn(emoji.Sprintf(":question: Unable to validate config against its schema: %s", err))
+		return nil
+	}
+
+	return component.ValidateConfig(generator)
 }
 
 var subcomponent string
 var environment string
 var noNewConfigKeys bool
 var inputFile string
+var stringValues bool
 
 var setCmd = &cobra.Command{
 	Use:   "set <config> [--subcomponent subcomponent] [--file <my-yaml-file.yaml>] <path1>=<value1> <path2>=<value2> ...",
@@ -163,13 +230,21 @@ Sets the subkey "replicas" in the key 'data' equal to 5 in the 'common' config (
 $ fab set --subcomponent "myapp.mysubcomponent" data.replicas=5 --no-new-config-keys
 
 Use the --no-new-config-keys switch to prevent the creation of new config.
+
+$ fab set ingress.hosts[0]=example.com tolerations[+].key=dedicated
+
+Sets the first item of the list 'ingress.hosts' equal to 'example.com' and appends an item with the 'key' 'dedicated'
+to the list 'tolerations'.
+
+Values are set as integers (4), booleans (true, false), or null (null, ~) where they parse as such, and as strings
+otherwise. Use the --string switch to set all values as strings.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 && inputFile == "" {
 			return errors.New("'set' takes one or more key=value arguments and/or a --file")
 		}
 
-		return Set(environment, subcomponent, args, noNewConfigKeys, inputFile)
+		return Set(environment, subcomponent, args, noNewConfigKeys, inputFile, stringValues)
 	},
 }
 
@@ -177,7 +252,8 @@ func init() {
 	setCmd.PersistentFlags().StringVar(&environment, "environment", "common", "Environment this configuration should apply to")
 	setCmd.PersistentFlags().StringVar(&subcomponent, "subcomponent", "", "Subcomponent this configuration should apply to")
 	setCmd.PersistentFlags().BoolVar(&noNewConfigKeys, "no-new-config-keys", false, "'Prevent creation of new config keys and only allow updating existing config values.")
-	setCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Path to a single YAML file which can be read in and the values of which will be set; note '.' can not occur in keys and list values are not supported.")
+	setCmd.PersistentFlags().BoolVar(&stringValues, "string", false, "Set all values as strings instead of parsing integers, booleans, and null.")
+	setCmd.Flags().StringVarP(&inputFile, "file", "f", "", "Path to a single YAML file which can be read in and the values of which will be set with their YAML types; lists are set as a whole.")
 
 	rootCmd.AddCommand(setCmd)
 }
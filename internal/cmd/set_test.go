@@ -22,50 +22,50 @@ func TestSetValue(t *testing.T) {
 	noNewConfigKeys := false
 
 	// malformed value assignment, should return error
-	err = Set("test", "", []string{"zoo"}, noNewConfigKeys, "")
+	err = Set("test", "", []string{"zoo"}, noNewConfigKeys, "", false)
 	assert.NotNil(t, err)
 
 	// malformed value assignment, should return error
-	err = Set("test", "", []string{"zoo=zaa=wrong"}, noNewConfigKeys, "")
+	err = Set("test", "", []string{"zoo=zaa=wrong"}, noNewConfigKeys, "", false)
 	assert.NotNil(t, err)
 
 	// apply 'faa' as value for 'foo' in component 'test' config
-	err = Set("test", "", []string{"foo=faa"}, noNewConfigKeys, "")
+	err = Set("test", "", []string{"foo=faa"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
 	// apply 'zaa' as value for 'zoo' in subcomponent 'myapp' 'test' config
-	err = Set("test", "myapp", []string{"zoo=zaa"}, noNewConfigKeys, "")
+	err = Set("test", "myapp", []string{"zoo=zaa"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
 	// create new environment
 	_ = os.Remove("./config/new.yaml")
-	err = Set("new", "myapp", []string{"zoo.zii=zaa"}, noNewConfigKeys, "")
+	err = Set("new", "myapp", []string{"zoo.zii=zaa"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
 	// update deep config on existing environment
-	err = Set("new", "myapp", []string{"zoo.zii=zbb"}, noNewConfigKeys, "")
+	err = Set("new", "myapp", []string{"zoo.zii=zbb"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
 	// deep subcomponent config set
-	err = Set("new", "myapp.mysubapp", []string{"foo.bar=zoo"}, noNewConfigKeys, "")
+	err = Set("new", "myapp.mysubapp", []string{"foo.bar=zoo"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
 	// deep subcomponent config set with string literal in double quotes. ex: \"k8.beta.io/load-balancer-group\"
-	err = Set("new", "myservice.mysubservice", []string{"foo.bar.\"k8.beta.io/load-balancer-group\"=foo-bar-group"}, noNewConfigKeys, "")
+	err = Set("new", "myservice.mysubservice", []string{"foo.bar.\"k8.beta.io/load-balancer-group\"=foo-bar-group"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
-	err = Set("new", "myservice.mysubservice", []string{"foo.bar.line=solid"}, noNewConfigKeys, "")
+	err = Set("new", "myservice.mysubservice", []string{"foo.bar.line=solid"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
 	// set existing value with new noNewConfigKeys switch on
 	noNewConfigKeys = true
-	err = Set("new", "myservice.mysubservice", []string{"foo.bar.\"k8.beta.io/load-balancer-group\"=foo-bar-updated"}, noNewConfigKeys, "")
+	err = Set("new", "myservice.mysubservice", []string{"foo.bar.\"k8.beta.io/load-balancer-group\"=foo-bar-updated"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
-	err = Set("test", "", []string{"foo=faa"}, noNewConfigKeys, "")
+	err = Set("test", "", []string{"foo=faa"}, noNewConfigKeys, "", false)
 	assert.Nil(t, err)
 
-	err = Set("test", "", []string{"newfoo=faa"}, noNewConfigKeys, "")
+	err = Set("test", "", []string{"newfoo=faa"}, noNewConfigKeys, "", false)
 	assert.NotNil(t, err)
 
 	////////////////////////////////////////////////////////////////////////////////
@@ -73,7 +73,7 @@ func TestSetValue(t *testing.T) {
 	////////////////////////////////////////////////////////////////////////////////
 	// Read target file to inject into myapp.subcomponent
 	yamlFile := "inject.yaml"
-	err = Set("fromfile", "myapp.mysubcomponent", []string{}, false, yamlFile)
+	err = Set("fromfile", "myapp.mysubcomponent", []string{}, false, yamlFile, false)
 	assert.Nil(t, err)
 	bytes, err := ioutil.ReadFile(yamlFile)
 	assert.Nil(t, err)
@@ -109,3 +109,84 @@ func TestSetValue(t *testing.T) {
 	// End Set from yaml file
 	////////////////////////////////////////////////////////////////////////////////
 }
+
+func TestSetTypedValues(t *testing.T) {
+	// This test changes the cwd. Must change back so any tests following don't break
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer func() {
+		_ = os.Chdir(cwd)
+	}()
+
+	dir, err := ioutil.TempDir("", "fabrikate-set")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	err = os.Chdir(dir)
+	assert.Nil(t, err)
+
+	err = Set("test", "", []string{"replicas=4", "enabled=true", "limit=null", "version=1.10", "mode=0755", "ingress.hosts[0]=a.example.com", "ingress.hosts[+]=b.example.com", "tolerations[+].key=dedicated"}, false, "", false)
+	assert.Nil(t, err)
+	err = Set("test", "", []string{"tag=4"}, false, "", true)
+	assert.Nil(t, err)
+
+	// indexes out of range fail
+	err = Set("test", "", []string{"ingress.hosts[5]=c.example.com"}, false, "", false)
+	assert.NotNil(t, err)
+
+	written, err := ioutil.ReadFile("config/test.yaml")
+	assert.Nil(t, err)
+	config := map[string]interface{}{}
+	err = yaml.Unmarshal(written, &config)
+	assert.Nil(t, err)
+
+	assert.EqualValues(t, map[string]interface{}{
+		"replicas": 4,
+		"enabled":  true,
+		"limit":    nil,
+		"version":  "1.10",
+		"mode":     "0755",
+		"tag":      "4",
+		"ingress": map[string]interface{}{
+			"hosts": []interface{}{"a.example.com", "b.example.com"},
+		},
+		"tolerations": []interface{}{
+			map[string]interface{}{"key": "dedicated"},
+		},
+	}, config["config"])
+}
+
+func TestSplitPathParts(t *testing.T) {
+	parts, err := SplitPathParts("ingress.hosts[0]")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ingress", "hosts", "[0]"}, parts)
+
+	parts, err = SplitPathParts("matrix[1][+].\"k8.beta.io/group\"")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"matrix", "[1]", "[+]", "k8.beta.io/group"}, parts)
+}
+
+func TestSetValidatesConfigSchema(t *testing.T) {
+	// This test changes the cwd. Must change back so any tests following don't break
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer func() {
+		_ = os.Chdir(cwd)
+	}()
+
+	err = os.Chdir("../../testdata/config-schema/app")
+	assert.Nil(t, err)
+	before, err := ioutil.ReadFile("config/common.yaml")
+	assert.Nil(t, err)
+
+	// the config file is left untouched if the config does not match the schema
+	err = Set("common", "", []string{"replicas=many"}, false, "", false)
+	assert.NotNil(t, err)
+	after, err := ioutil.ReadFile("config/common.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	err = Set("prod", "", []string{"replicaCount=3"}, false, "", false)
+	assert.NotNil(t, err)
+	_, err = os.Stat("config/prod.yaml")
+	assert.True(t, os.IsNotExist(err))
+}
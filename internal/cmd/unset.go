@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/spf13/cobra"
+)
+
+// Unset implements the 'unset' command. It takes an environment, a set of config paths (and a subcomponent
+// if the config should be removed from a subcomponent versus the component itself) and removes the config
+// from the appropriate config file, along with any maps left empty, writing the result out to disk at the end.
+func Unset(environment string, subcomponent string, paths []string) (err error) {
+	subcomponentPath := []string{}
+	if len(subcomponent) > 0 {
+		subcomponentPath = strings.Split(subcomponent, ".")
+	}
+
+	componentConfig := core.NewComponentConfig(".")
+	if err := componentConfig.Load(environment); err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		pathParts, err := SplitPathParts(path)
+		if err != nil {
+			return err
+		}
+
+		if !componentConfig.HasSubcomponentConfig(subcomponentPath) {
+			return fmt.Errorf("%s is not set in the '%s' config", path, environment)
+		}
+
+		subcomponentConfig := componentConfig.GetSubcomponentConfig(subcomponentPath)
+		if !subcomponentConfig.HasComponentConfig(pathParts) {
+			return fmt.Errorf("%s is not set in the '%s' config", path, environment)
+		}
+
+		componentConfig.UnsetConfig(subcomponentPath, pathParts)
+	}
+
+	return componentConfig.Write(environment)
+}
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset [--environment environment] [--subcomponent subcomponent] <path1> <path2> ...",
+	Short: "Removes a config value for a component for a particular config environment in the Fabrikate definition.",
+	Long: `Removes a config value for a component for a particular config environment in the Fabrikate definition.
+Maps and subcomponent configs left empty by the removal are removed as well.
+eg.
+$ fab unset --environment prod data.replicas username
+
+Removes 'data.replicas' and 'username' from the 'prod' config for the current component.
+
+$ fab unset --subcomponent "myapp.mysubcomponent" data.replicas
+
+Removes 'data.replicas' from the 'common' config (the default) for the subcomponent 'mysubcomponent' of the
+subcomponent 'myapp'.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("'unset' takes one or more config paths")
+		}
+
+		return Unset(cmd.Flag("environment").Value.String(), cmd.Flag("subcomponent").Value.String(), args)
+	},
+}
+
+func init() {
+	unsetCmd.PersistentFlags().String("environment", "common", "Environment this configuration should be removed from")
+	unsetCmd.PersistentFlags().String("subcomponent", "", "Subcomponent this configuration should be removed from")
+	rootCmd.AddCommand(unsetCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnset(t *testing.T) {
+	// This test changes the cwd. Must change back so any tests following don't break
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer func() {
+		_ = os.Chdir(cwd)
+	}()
+
+	dir, err := ioutil.TempDir("", "fabrikate-unset")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	err = os.Chdir(dir)
+	assert.Nil(t, err)
+
+	err = Set("test", "", []string{"foo=faa", "data.replicas=3"}, false, "", false)
+	assert.Nil(t, err)
+	err = Set("test", "myapp.mysubapp", []string{"zoo.zii=zaa"}, false, "", false)
+	assert.Nil(t, err)
+
+	// removing a value which is not set fails
+	err = Unset("test", "", []string{"data.storageClass"})
+	assert.NotNil(t, err)
+	err = Unset("test", "myapp.otherapp", []string{"zoo.zii"})
+	assert.NotNil(t, err)
+
+	err = Unset("test", "", []string{"data.replicas"})
+	assert.Nil(t, err)
+	err = Unset("test", "myapp.mysubapp", []string{"zoo.zii"})
+	assert.Nil(t, err)
+
+	// the maps and subcomponent configs left empty are removed
+	written, err := ioutil.ReadFile(path.Join("config", "test.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "config:\n  foo: faa\n", string(written))
+}
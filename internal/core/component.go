@@ -16,6 +16,7 @@ import (
 	"github.com/kyokomi/emoji"
 	"github.com/microsoft/fabrikate/internal/git"
 	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/microsoft/fabrikate/internal/manifest"
 	"github.com/timfpark/yaml"
 )
 
@@ -32,6 +33,9 @@ type Component struct {
 	Path          string              `yaml:"path,omitempty" json:"path,omitempty"`
 	Version       string              `yaml:"version,omitempty" json:"version,omitempty"`
 	Branch        string              `yaml:"branch,omitempty" json:"branch,omitempty"`
+	DependsOn     []string            `yaml:"dependsOn,omitempty" json:"dependsOn,omitempty"`
+	ConfigSchema  string              `yaml:"configSchema,omitempty" json:"configSchema,omitempty"`
+	Exclude       []manifest.Selector `yaml:"exclude,omitempty" json:"exclude,omitempty"`
 
 	Repositories  map[string]string `yaml:"repositories,omitempty" json:"repositories,omitempty"`
 	Subcomponents []Component       `yaml:"subcomponents,omitempty" json:"subcomponents,omitempty"`
@@ -39,6 +43,12 @@ type Component struct {
 	PhysicalPath string `yaml:"-" json:"-"`
 	LogicalPath  string `yaml:"-" json:"-"`
 
+	SubcomponentPath []string         `yaml:"-" json:"-"` // names of the subcomponents leading from the root component to this one
+	ConfigSources    []ConfigSource   `yaml:"-" json:"-"` // config files the config was merged from, in order of precedence
+	GlobalSources    [][]ConfigSource `yaml:"-" json:"-"` // config files the global config of the component and of each of its ancestors was merged from
+	Environments     []string         `yaml:"-" json:"-"` // environments the config was loaded for, with those they extend, in order of precedence
+	SkippedManifests []string         `yaml:"-" json:"-"` // encrypted manifests left out of the generated manifest, as decryption is disabled
+
 	Manifest string `yaml:"-" json:"-"`
 }
 
@@ -119,20 +129,67 @@ func (c *Component) LoadComponent() (loadedComponent Component, err error) {
 
 	loadedComponent.PhysicalPath = c.PhysicalPath
 	loadedComponent.LogicalPath = c.LogicalPath
+	loadedComponent.SubcomponentPath = c.SubcomponentPath
+	loadedComponent.ConfigSources = c.ConfigSources
+	loadedComponent.Exclude = append(append([]manifest.Selector{}, c.Exclude...), loadedComponent.Exclude...)
 	err = loadedComponent.Config.Merge(c.Config)
 
 	return loadedComponent, err
 }
 
-// LoadConfig loads and merges the config specified by the passed set of environments.
+// LoadConfig loads and merges the config specified by the passed set of environments, each followed by the
+// environments its config file extends, recursively, and then by the common config.
 func (c *Component) LoadConfig(environments []string) (err error) {
+	c.Environments = []string{}
+
+	// load merges the config of `environment` and of those it extends; `extending` are the environments
+	// extending it, to detect cycles
+	var load func(environment string, extending []string) error
+	load = func(environment string, extending []string) error {
+		for index, extendingEnvironment := range extending {
+			if extendingEnvironment == environment {
+				return fmt.Errorf("environments of component '%s' extend each other: %s -> %s", c.Name, strings.Join(extending[index:], " -> "), environment)
+			}
+		}
+		if environment == "common" || containsString(c.Environments, environment) {
+			return nil
+		}
+		c.Environments = append(c.Environments, environment)
+
+		componentConfig := NewComponentConfig(c.PhysicalPath)
+		if err := componentConfig.Load(environment); err != nil {
+			return err
+		}
+		if err := c.Config.Merge(componentConfig); err != nil {
+			return err
+		}
+		if configPath := configFilePath(c.PhysicalPath, environment); configPath != "" {
+			c.ConfigSources = append(c.ConfigSources, ConfigSource{File: configPath, Component: c.Name})
+		}
+
+		for _, extended := range componentConfig.Extends {
+			if err := load(extended, append(append([]string{}, extending...), environment)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
 	for _, environment := range environments {
-		if err := c.Config.MergeConfigFile(c.PhysicalPath, environment); err != nil {
+		if err := load(environment, nil); err != nil {
 			return err
 		}
 	}
 
-	return c.Config.MergeConfigFile(c.PhysicalPath, "common")
+	if err := c.Config.MergeConfigFile(c.PhysicalPath, "common"); err != nil {
+		return err
+	}
+	if configPath := configFilePath(c.PhysicalPath, "common"); configPath != "" {
+		c.ConfigSources = append(c.ConfigSources, ConfigSource{File: configPath, Component: c.Name})
+	}
+
+	return nil
 }
 
 // RelativePathTo returns the relative filesystem path where this component should be.
@@ -209,19 +266,28 @@ func (c *Component) InstallComponent(componentPath string) (err error) {
 				return err
 			}
 
-			// delete the subcomponent if previously installed
 			subcomponentPath := path.Join(componentPath, c.RelativePathTo())
+			cloneOpts := git.CloneOpts{
+				URL:    c.Source,
+				SHA:    c.Version,
+				Branch: c.Branch,
+				Into:   subcomponentPath}
+
+			// offline installs keep the previously installed subcomponent if it is not cached
+			if Offline.Enabled() {
+				description := fmt.Sprintf("git repository '%s' (branch '%s', version '%s') of component '%s'", c.Source, c.Branch, c.Version, c.Name)
+				return InstallOffline(description, subcomponentPath, func() error {
+					return CloneLocked(cloneOpts)
+				})
+			}
+
+			// delete the subcomponent if previously installed
 			if err = os.RemoveAll(subcomponentPath); err != nil {
 				return err
 			}
 
 			logger.Info(emoji.Sprintf(":helicopter: Installing component '%s' with git from '%s'", c.Name, c.Source))
-			cloneOpts := &git.CloneOpts{
-				URL:    c.Source,
-				SHA:    c.Version,
-				Branch: c.Branch,
-				Into:   subcomponentPath}
-			if err = git.Clone(cloneOpts); err != nil {
+			if err = CloneLocked(cloneOpts); err != nil {
 				return err
 			}
 			return nil
@@ -261,8 +327,12 @@ func (c *Component) Install(componentPath string, generator Generator) (err erro
 		return err
 	}
 
-	// Install subcomponents
-	for _, subcomponent := range c.Subcomponents {
+	// Install subcomponents; siblings they depend on first
+	subcomponents, err := OrderSubcomponents(c.Name, c.Subcomponents)
+	if err != nil {
+		return err
+	}
+	for _, subcomponent := range subcomponents {
 		if err = subcomponent.applyDefaultsAndMigrations(); err != nil {
 			return err
 		}
@@ -288,6 +358,10 @@ func (c *Component) Generate(generator Generator) (err error) {
 		return err
 	}
 
+	if err := c.ValidateConfig(generator); err != nil {
+		return err
+	}
+
 	if generator != nil {
 		c.Manifest, err = generator.Generate(c)
 	} else {
@@ -299,13 +373,118 @@ func (c *Component) Generate(generator Generator) (err error) {
 		return err
 	}
 
+	if err := c.excludeObjects(); err != nil {
+		return err
+	}
+
+	if err := c.injectNamespace(); err != nil {
+		return err
+	}
+
+	if err := c.addCommonMetadata(); err != nil {
+		return err
+	}
+
 	return c.afterGenerate()
 }
 
+// excludeObjects removes the objects selected by `exclude` from the generated manifest.
+func (c *Component) excludeObjects() (err error) {
+	if len(c.Exclude) == 0 {
+		return nil
+	}
+
+	var excluded []manifest.Identity
+	c.Manifest, excluded, err = manifest.Filter(c.Manifest, func(id manifest.Identity) bool {
+		for _, selector := range c.Exclude {
+			if selector.Matches(id) {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("error excluding objects from the manifest generated for component '%s': %w", c.Name, err)
+	}
+
+	for _, id := range excluded {
+		logger.Info(emoji.Sprintf(":scissors: Excluding %s from component '%s'", id, c.Name))
+	}
+
+	return nil
+}
+
+// injectNamespace injects the namespace of the config into every namespaced object of the generated
+// manifest which does not specify one if the component opts into it via `injectNamespace`, and adds the
+// Namespace object itself if it opts into it via `createNamespace`.
+func (c *Component) injectNamespace() (err error) {
+	namespace := c.Config.Namespace
+	if namespace == "" {
+		return nil
+	}
+
+	if c.Config.InjectNamespace {
+		logger.Info(emoji.Sprintf(":syringe: Injecting namespace '%s' into manifests for component '%s'", namespace, c.Name))
+		if c.Manifest, err = manifest.InjectNamespace(c.Manifest, namespace, c.Config.ClusterScopedKinds); err != nil {
+			return fmt.Errorf("error injecting namespace into the manifest generated for component '%s': %w", c.Name, err)
+		}
+	}
+
+	if c.Config.CreateNamespace {
+		objects, err := manifest.Parse(c.Manifest)
+		if err != nil {
+			return fmt.Errorf("error parsing the manifest generated for component '%s': %w", c.Name, err)
+		}
+		for _, object := range objects {
+			if id := object.Identity(); id.Kind == "Namespace" && id.Name == namespace {
+				return nil
+			}
+		}
+
+		namespaceObject, err := manifest.NamespaceObject(namespace)
+		if err != nil {
+			return err
+		}
+
+		logger.Info(emoji.Sprintf(":house: Adding namespace '%s' to manifests for component '%s'", namespace, c.Name))
+		c.Manifest = namespaceObject + c.Manifest
+	}
+
+	return nil
+}
+
+// addCommonMetadata adds the `commonLabels` and `commonAnnotations` of the config to every object of the
+// generated manifest.
+func (c *Component) addCommonMetadata() (err error) {
+	if len(c.Config.CommonLabels) == 0 && len(c.Config.CommonAnnotations) == 0 {
+		return nil
+	}
+
+	logger.Info(emoji.Sprintf(":label: Adding common labels and annotations to manifests for component '%s'", c.Name))
+	c.Manifest, err = manifest.AddMetadata(c.Manifest, manifest.CommonMetadata{
+		Labels:      c.Config.CommonLabels,
+		Annotations: c.Config.CommonAnnotations,
+		Templates:   c.Config.CommonMetadataInTemplates,
+		Selectors:   c.Config.CommonLabelsInSelectors,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding common labels and annotations to the manifest generated for component '%s': %w", c.Name, err)
+	}
+
+	return nil
+}
+
 type componentIteration func(path string, component *Component) (err error)
 
 type rootComponentInit func(startingPath string, environments []string, c Component) (component Component, err error)
 
+// walkItem is a component enqueued to be visited by WalkComponentTree.
+type walkItem struct {
+	component Component
+	waitFor   []chan struct{} // closed once the siblings this component depends on are walked
+	done      chan struct{}   // closed once this component and all its subcomponents are walked
+}
+
 // WalkResult is what WalkComponentTree returns.
 // Will contain either a Component OR an Error (Error is nillable; meaning both fields can be nil)
 type WalkResult struct {
@@ -318,10 +497,11 @@ type WalkResult struct {
 //
 // Returns a channel of WalkResult which can either have a Component or an Error (Error is nillable)
 //
-// Same level ordering is not ensured; any nodes on the same tree level can be visited in any order.
+// Same level ordering is only ensured via `dependsOn`; a node is visited after the siblings it depends on
+// and all of their children are visited. Any other nodes on the same tree level can be visited in any order.
 // Parent->Child ordering is ensured; A parent is always visited via `iterator` before the children are visited.
 func WalkComponentTree(startingPath string, environments []string, iterator componentIteration, rootInit rootComponentInit) <-chan WalkResult {
-	queue := make(chan Component)    // components enqueued to be 'visited' (ie; walked over)
+	queue := make(chan walkItem)     // components enqueued to be 'visited' (ie; walked over)
 	results := make(chan WalkResult) // To pass WalkResults to
 	walking := sync.WaitGroup{}      // Keep track of all nodes being worked on
 
@@ -342,12 +522,14 @@ func WalkComponentTree(startingPath string, environments []string, iterator comp
 		return c
 	}
 
-	// Enqueue the given component
-	enqueue := func(c Component) {
+	// Enqueue the given component; it is visited once all of `waitFor` are closed
+	enqueue := func(c Component, waitFor []chan struct{}) chan struct{} {
 		// Increment working counter; MUST happen BEFORE sending to queue or race condition can occur
 		walking.Add(1)
 		logger.Debug(fmt.Sprintf("Adding subcomponent '%s' to queue with physical path '%s' and logical path '%s'\n", c.Name, c.PhysicalPath, c.LogicalPath))
-		queue <- c
+		done := make(chan struct{})
+		queue <- walkItem{component: c, waitFor: waitFor, done: done}
+		return done
 	}
 
 	// Mark a component as visited and report it back as a result; decrements the walking counter
@@ -372,7 +554,16 @@ func WalkComponentTree(startingPath string, environments []string, iterator comp
 		if err != nil {
 			results <- WalkResult{Error: err}
 		} else {
-			enqueue(rootComponent)
+			// The root component inherits nothing, but its global config applies to itself as to its subtree
+			rootComponent.Config.Inherit(ComponentConfig{})
+			rootComponent.inheritGlobalSources(Component{})
+
+			// The environments extended by those of the root component are loaded for the whole tree
+			if len(rootComponent.Environments) > len(environments) {
+				logger.Info(emoji.Sprintf(":link: Environments %s extend to %s", strings.Join(environments, ", "), strings.Join(rootComponent.Environments, ", ")))
+				environments = rootComponent.Environments
+			}
+			enqueue(rootComponent, nil)
 		}
 
 		// Close results channel once all nodes visited
@@ -382,8 +573,26 @@ func WalkComponentTree(startingPath string, environments []string, iterator comp
 
 	// Worker thread to pull from queue and call the iterator
 	go func() {
-		for queuedComponent := range queue {
-			go func(c Component) {
+		for queuedItem := range queue {
+			go func(item walkItem) {
+				c := item.component
+
+				// Wait for the siblings this component depends on
+				for _, dependency := range item.waitFor {
+					<-dependency
+				}
+
+				// Mark the subtree as walked once all enqueued subcomponents are
+				subtree := map[string]chan struct{}{}
+				defer func() {
+					go func() {
+						for _, done := range subtree {
+							<-done
+						}
+						close(item.done)
+					}()
+				}()
+
 				// Decrement working counter; Must happen AFTER the subcomponents are enqueued
 				defer markAsVisited(&c)
 
@@ -393,10 +602,17 @@ func WalkComponentTree(startingPath string, environments []string, iterator comp
 					results <- WalkResult{Error: err}
 				}
 
-				// Range over subcomponents; preparing and enqueuing
-				for _, subcomponent := range c.Subcomponents {
+				// Range over subcomponents in dependency order; preparing and enqueuing
+				subcomponents, err := OrderSubcomponents(c.Name, c.Subcomponents)
+				if err != nil {
+					results <- WalkResult{Error: err}
+					return
+				}
+				for _, subcomponent := range subcomponents {
 					// Prep component config
 					subcomponent.Config = c.Config.Subcomponents[subcomponent.Name]
+					subcomponent.SubcomponentPath = append(append([]string{}, c.SubcomponentPath...), subcomponent.Name)
+					subcomponent.ConfigSources = c.subcomponentConfigSources(subcomponent.Name)
 
 					if err = subcomponent.applyDefaultsAndMigrations(); err != nil {
 						results <- WalkResult{Error: err}
@@ -423,11 +639,21 @@ func WalkComponentTree(startingPath string, environments []string, iterator comp
 						subcomponent.PhysicalPath = c.PhysicalPath
 						subcomponent.LogicalPath = c.LogicalPath
 					}
+					subcomponent.Config.Inherit(c.Config)
+					subcomponent.inheritGlobalSources(c)
+
+					// Disabled siblings are never walked; there is nothing to wait for
+					waitFor := []chan struct{}{}
+					for _, dependency := range subcomponent.DependsOn {
+						if done, enqueued := subtree[dependency]; enqueued {
+							waitFor = append(waitFor, done)
+						}
+					}
 
 					logger.Debug(fmt.Sprintf("Adding subcomponent '%s' to queue with physical path '%s' and logical path '%s'\n", subcomponent.Name, subcomponent.PhysicalPath, subcomponent.LogicalPath))
-					enqueue(subcomponent)
+					subtree[subcomponent.Name] = enqueue(subcomponent, waitFor)
 				}
-			}(queuedComponent)
+			}(queuedItem)
 		}
 	}()
 
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -76,6 +78,39 @@ func TestLoadConfig(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestLoadConfigExtends(t *testing.T) {
+	component := Component{
+		PhysicalPath: "../../testdata/extends",
+		LogicalPath:  "./",
+	}
+
+	component, err := component.LoadComponent()
+	assert.Nil(t, err)
+
+	err = component.LoadConfig([]string{"prod-east"})
+	assert.Nil(t, err)
+
+	// Extended environments follow the environment extending them, recursively
+	assert.Equal(t, []string{"prod-east", "prod", "azure", "cloud", "east"}, component.Environments)
+	assert.Equal(t, map[string]interface{}{
+		"region":     "prod-east",
+		"tier":       "production",
+		"cloud":      "azure",
+		"monitoring": "enabled",
+		"zone":       "east-1",
+	}, component.Config.Config)
+
+	component = Component{
+		PhysicalPath: "../../testdata/extends-cycle",
+		LogicalPath:  "./",
+	}
+	component, err = component.LoadComponent()
+	assert.Nil(t, err)
+
+	err = component.LoadConfig([]string{"a"})
+	assert.EqualError(t, err, "environments of component 'extends-cycle' extend each other: a -> b -> c -> a")
+}
+
 func TestUpdateRootComponentPath(t *testing.T) {
 	component := Component{
 		PhysicalPath: "../../testdata/definition/infra-single",
@@ -128,6 +163,68 @@ func TestIteratingDefinition(t *testing.T) {
 	assert.Equal(t, components[2].LogicalPath, "infra/efk")
 }
 
+func TestIteratingDependsOn(t *testing.T) {
+	rootInit := func(startPath string, environments []string, c Component) (component Component, err error) {
+		return c, nil
+	}
+
+	visitedMutex := sync.Mutex{}
+	visited := []string{}
+	results := WalkComponentTree("../../testdata/depends-on", []string{}, func(path string, component *Component) (err error) {
+		// Give siblings without an ordering constraint the chance to overtake
+		if component.Name == "crds" {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		visitedMutex.Lock()
+		visited = append(visited, component.Name)
+		visitedMutex.Unlock()
+		return nil
+	}, rootInit)
+
+	components, err := SynchronizeWalkResult(results)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(components))
+
+	// 'app' depends on 'crds' and so is visited after it and its subcomponents
+	assert.Equal(t, []string{"depends-on", "crds", "definitions", "app"}, visited)
+}
+
+func TestOrderSubcomponents(t *testing.T) {
+	names := func(components []Component) (names []string) {
+		for _, component := range components {
+			names = append(names, component.Name)
+		}
+		return names
+	}
+
+	// Siblings without dependencies keep their order
+	ordered, err := OrderSubcomponents("root", []Component{
+		{Name: "app", DependsOn: []string{"crds", "namespaces"}},
+		{Name: "monitoring"},
+		{Name: "crds", DependsOn: []string{"namespaces"}},
+		{Name: "namespaces"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"monitoring", "namespaces", "crds", "app"}, names(ordered))
+
+	// Unknown siblings are an error
+	_, err = OrderSubcomponents("root", []Component{
+		{Name: "app", DependsOn: []string{"crds"}},
+	})
+	assert.NotNil(t, err)
+
+	// Cycles are an error which contains the cycle
+	_, err = OrderSubcomponents("root", []Component{
+		{Name: "monitoring"},
+		{Name: "app", DependsOn: []string{"crds"}},
+		{Name: "crds", DependsOn: []string{"namespaces"}},
+		{Name: "namespaces", DependsOn: []string{"app"}},
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "app -> crds -> namespaces -> app")
+}
+
 func TestWriteComponent(t *testing.T) {
 	component := Component{
 		PhysicalPath: "../../testdata/install",
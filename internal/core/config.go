@@ -6,7 +6,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
-	"strings"
 
 	"github.com/kyokomi/emoji"
 	"github.com/microsoft/fabrikate/internal/logger"
@@ -16,13 +15,23 @@ import (
 
 // ComponentConfig documentation: https://github.com/microsoft/fabrikate/blob/master/docs/config.md
 type ComponentConfig struct {
-	Path            string                     `yaml:"-" json:"-"`
-	Serialization   string                     `yaml:"-" json:"-"`
-	Namespace       string                     `yaml:"namespace,omitempty" json:"namespace,omitempty"`
-	InjectNamespace bool                       `yaml:"injectNamespace,omitempty" json:"injectNamespace,omitempty"`
-	Disabled        bool                       `yaml:"disabled,omitempty" json:"disabled,omitempty"`
-	Config          map[string]interface{}     `yaml:"config,omitempty" json:"config,omitempty"`
-	Subcomponents   map[string]ComponentConfig `yaml:"subcomponents,omitempty" json:"subcomponents,omitempty"`
+	Path                      string                     `yaml:"-" json:"-"`
+	Serialization             string                     `yaml:"-" json:"-"`
+	Extends                   []string                   `yaml:"extends,omitempty" json:"extends,omitempty"`
+	Namespace                 string                     `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	InjectNamespace           bool                       `yaml:"injectNamespace,omitempty" json:"injectNamespace,omitempty"`
+	CreateNamespace           bool                       `yaml:"createNamespace,omitempty" json:"createNamespace,omitempty"`
+	ClusterScopedKinds        []string                   `yaml:"clusterScopedKinds,omitempty" json:"clusterScopedKinds,omitempty"`
+	CommonLabels              map[string]string          `yaml:"commonLabels,omitempty" json:"commonLabels,omitempty"`
+	CommonAnnotations         map[string]string          `yaml:"commonAnnotations,omitempty" json:"commonAnnotations,omitempty"`
+	CommonMetadataInTemplates bool                       `yaml:"commonMetadataInTemplates,omitempty" json:"commonMetadataInTemplates,omitempty"`
+	CommonLabelsInSelectors   bool                       `yaml:"commonLabelsInSelectors,omitempty" json:"commonLabelsInSelectors,omitempty"`
+	Disabled                  bool                       `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Global                    map[string]interface{}     `yaml:"global,omitempty" json:"global,omitempty"`
+	MergeStrategies           map[string]string          `yaml:"mergeStrategies,omitempty" json:"mergeStrategies,omitempty"`
+	Config                    map[string]interface{}     `yaml:"config,omitempty" json:"config,omitempty"`
+	Subcomponents             map[string]ComponentConfig `yaml:"subcomponents,omitempty" json:"subcomponents,omitempty"`
+	Encrypted                 bool                       `yaml:"-" json:"-"` // loaded from encrypted config files; never written
 }
 
 // NewComponentConfig creates a ComponentConfig at the passed path.
@@ -40,16 +49,33 @@ func (cc *ComponentConfig) GetPath(environment string) string {
 	return path.Join(cc.Path, configFilename)
 }
 
+// decrypting wraps `unmarshalFunc` to mark this config as encrypted if the config file at `configPath` is
+// encrypted with sops, and to decrypt it first if Decryption is enabled.
+func (cc *ComponentConfig) decrypting(configPath string, unmarshalFunc unmarshalFunction) unmarshalFunction {
+	return func(in []byte, out interface{}) (err error) {
+		if IsEncryptedFile(configPath, in) {
+			cc.Encrypted = true
+			if Decryption.Enabled() {
+				if in, err = Decryption.Decrypt(configPath); err != nil {
+					return err
+				}
+			}
+		}
+
+		return unmarshalFunc(in, out)
+	}
+}
+
 // UnmarshalJSONConfig unmarshals the JSON config file for the specified environment.
 func (cc *ComponentConfig) UnmarshalJSONConfig(environment string) (err error) {
 	cc.Serialization = "json"
-	return UnmarshalFile(cc.GetPath(environment), json.Unmarshal, &cc)
+	return UnmarshalFile(cc.GetPath(environment), cc.decrypting(cc.GetPath(environment), json.Unmarshal), &cc)
 }
 
 // UnmarshalYAMLConfig unmarshals the YAML config file for the specified environment.
 func (cc *ComponentConfig) UnmarshalYAMLConfig(environment string) (err error) {
 	cc.Serialization = "yaml"
-	return UnmarshalFile(cc.GetPath(environment), yaml.Unmarshal, &cc)
+	return UnmarshalFile(cc.GetPath(environment), cc.decrypting(cc.GetPath(environment), yaml.Unmarshal), &cc)
 }
 
 // MergeConfigFile loads the config for the specified environment and path and
@@ -63,8 +89,18 @@ func (cc *ComponentConfig) MergeConfigFile(path string, environment string) (err
 	return cc.Merge(componentConfig)
 }
 
-// Load loads the config for the specified environment.
+// Load loads the config for the specified environment, along with the config of its encrypted config file
+// (eg. config/prod.enc.yaml) if Decryption is enabled.
 func (cc *ComponentConfig) Load(environment string) (err error) {
+	if err = cc.loadConfigFile(environment); err != nil {
+		return err
+	}
+
+	return cc.mergeEncryptedConfigFile(environment)
+}
+
+// loadConfigFile loads the YAML or JSON config file for the specified environment.
+func (cc *ComponentConfig) loadConfigFile(environment string) (err error) {
 	// If success or loading or parsing the file failed for reasons other than it didn't exist, return.
 	if err = cc.UnmarshalYAMLConfig(environment); err == nil || !os.IsNotExist(err) {
 		return err
@@ -80,48 +116,79 @@ func (cc *ComponentConfig) Load(environment string) (err error) {
 	return nil
 }
 
-// HasComponentConfig checks if the component contains the given component configuration.
-// The given component is specified via a configuration `path`.
-// Returns true if it contains it, otherwise it returns false.
-func (cc *ComponentConfig) HasComponentConfig(path []string) bool {
-	configLevel := cc.Config
+// mergeEncryptedConfigFile decrypts the encrypted config file for the specified environment, if any, and
+// merges it with this config; values set by both are taken from this config. Nothing is done unless
+// Decryption is enabled.
+func (cc *ComponentConfig) mergeEncryptedConfigFile(environment string) (err error) {
+	if !Decryption.Enabled() {
+		return nil
+	}
 
-	for levelIndex, pathPart := range path {
-		// if this key is not the final one, we need to decend in the config.
-		if _, ok := configLevel[pathPart]; !ok {
-			return false
+	for _, serialization := range []string{"yaml", "json"} {
+		encryptedPath := path.Join(cc.Path, "config", fmt.Sprintf("%s%s.%s", environment, EncryptedSuffix, serialization))
+		if _, err := os.Stat(encryptedPath); os.IsNotExist(err) {
+			continue
 		}
 
-		if levelIndex < len(path)-1 {
-			configLevel = configLevel[pathPart].(map[string]interface{})
+		decrypted, err := Decryption.Decrypt(encryptedPath)
+		if err != nil {
+			return err
+		}
+		encryptedConfig := NewComponentConfig(cc.Path)
+		if err = yaml.Unmarshal(decrypted, &encryptedConfig); err != nil {
+			return fmt.Errorf("error parsing decrypted config file '%s': %w", encryptedPath, err)
 		}
+
+		cc.Encrypted = true
+		return cc.Merge(encryptedConfig)
 	}
 
-	return true
+	return nil
+}
+
+// HasComponentConfig checks if the component contains the given component configuration.
+// The given component is specified via a configuration `path`.
+// Returns true if it contains it, otherwise it returns false.
+func (cc *ComponentConfig) HasComponentConfig(path []string) bool {
+	_, ok := cc.GetComponentConfig(path)
+	return ok
+}
+
+// GetComponentConfig returns the value of the given configuration setting and
+// whether it is set. The configuration setting is indicated via a configuration `path`,
+// which may index into lists with `[N]` parts.
+func (cc *ComponentConfig) GetComponentConfig(path []string) (value interface{}, ok bool) {
+	return getConfigValue(cc.Config, path)
 }
 
 // SetComponentConfig sets the `value` of the given configuration setting.
-// The configuration setting is indicated via a configuration `path`.
-func (cc *ComponentConfig) SetComponentConfig(path []string, value string) {
-	configLevel := cc.Config
-	createdNewConfig := false
-
-	for levelIndex, pathPart := range path {
-		// if this key is not the final one, we need to decend in the config.
-		if levelIndex < len(path)-1 {
-			if _, ok := configLevel[pathPart]; !ok {
-				createdNewConfig = true
-				configLevel[pathPart] = map[string]interface{}{}
-			}
+// The configuration setting is indicated via a configuration `path`, which may index
+// into lists with `[N]` parts or append to them with `[+]` parts.
+func (cc *ComponentConfig) SetComponentConfig(path []string, value interface{}) error {
+	config, createdNewConfig, err := setConfigValue(cc.Config, path, value)
+	if err != nil {
+		return fmt.Errorf("error setting %s: %w", JoinConfigPath(path), err)
+	}
 
-			configLevel = configLevel[pathPart].(map[string]interface{})
-		} else {
-			if createdNewConfig {
-				logger.Info(emoji.Sprintf(":seedling: Created new value for %s", strings.Join(path, ".")))
-			}
-			configLevel[pathPart] = value
-		}
+	if createdNewConfig {
+		logger.Info(emoji.Sprintf(":seedling: Created new value for %s", JoinConfigPath(path)))
 	}
+	cc.Config = config.(map[string]interface{})
+
+	return nil
+}
+
+// UnsetComponentConfig removes the given configuration setting, along with the
+// maps and lists left empty by its removal. The configuration setting is indicated
+// via a configuration `path`.
+func (cc *ComponentConfig) UnsetComponentConfig(path []string) {
+	unsetConfigValue(cc.Config, path)
+}
+
+// isEmpty returns whether this componentConfig holds no configuration at all.
+func (cc *ComponentConfig) isEmpty() bool {
+	return len(cc.Extends) == 0 && cc.Namespace == "" && !cc.InjectNamespace && !cc.CreateNamespace && len(cc.ClusterScopedKinds) == 0 && len(cc.CommonLabels) == 0 && len(cc.CommonAnnotations) == 0 &&
+		!cc.CommonMetadataInTemplates && !cc.CommonLabelsInSelectors && !cc.Disabled && len(cc.Global) == 0 && len(cc.MergeStrategies) == 0 && len(cc.Config) == 0 && len(cc.Subcomponents) == 0
 }
 
 // GetSubcomponentConfig returns the subcomponent config of the given component.
@@ -168,9 +235,45 @@ func (cc *ComponentConfig) HasSubcomponentConfig(subcomponentPath []string) bool
 }
 
 // SetConfig sets or creates the configuration `value` for the given `subcomponentPath`.
-func (cc *ComponentConfig) SetConfig(subcomponentPath []string, path []string, value string) {
-	subcomponentConfig := cc.GetSubcomponentConfig(subcomponentPath)
-	subcomponentConfig.SetComponentConfig(path, value)
+func (cc *ComponentConfig) SetConfig(subcomponentPath []string, path []string, value interface{}) error {
+	if len(subcomponentPath) == 0 {
+		return cc.SetComponentConfig(path, value)
+	}
+
+	if cc.Subcomponents == nil {
+		cc.Subcomponents = map[string]ComponentConfig{}
+	}
+
+	subcomponentName := subcomponentPath[0]
+	subcomponentConfig := cc.GetSubcomponentConfig(subcomponentPath[:1])
+	if err := subcomponentConfig.SetConfig(subcomponentPath[1:], path, value); err != nil {
+		return err
+	}
+	cc.Subcomponents[subcomponentName] = subcomponentConfig
+
+	return nil
+}
+
+// UnsetConfig removes the configuration at `path` for the given `subcomponentPath`, along with the
+// maps and subcomponent configurations left empty by its removal.
+func (cc *ComponentConfig) UnsetConfig(subcomponentPath []string, path []string) {
+	if len(subcomponentPath) == 0 {
+		cc.UnsetComponentConfig(path)
+		return
+	}
+
+	subcomponentName := subcomponentPath[0]
+	subcomponentConfig, ok := cc.Subcomponents[subcomponentName]
+	if !ok {
+		return
+	}
+
+	subcomponentConfig.UnsetConfig(subcomponentPath[1:], path)
+	if subcomponentConfig.isEmpty() {
+		delete(cc.Subcomponents, subcomponentName)
+	} else {
+		cc.Subcomponents[subcomponentName] = subcomponentConfig
+	}
 }
 
 // MergeNamespaces merges the namespaces between the componentConfig passed and this
@@ -179,6 +282,7 @@ func (cc *ComponentConfig) MergeNamespaces(newConfig ComponentConfig) ComponentC
 	if cc.Namespace == "" {
 		cc.Namespace = newConfig.Namespace
 		cc.InjectNamespace = newConfig.InjectNamespace
+		cc.CreateNamespace = newConfig.CreateNamespace
 	}
 
 	for key, config := range cc.Subcomponents {
@@ -188,15 +292,109 @@ func (cc *ComponentConfig) MergeNamespaces(newConfig ComponentConfig) ComponentC
 	return *cc
 }
 
+// MergeCommonMetadataFlags merges the flags directing where the common labels and annotations are added
+// between the componentConfig passed and this ComponentConfig; they are set if set in either.
+func (cc *ComponentConfig) MergeCommonMetadataFlags(newConfig ComponentConfig) ComponentConfig {
+	cc.CommonMetadataInTemplates = cc.CommonMetadataInTemplates || newConfig.CommonMetadataInTemplates
+	cc.CommonLabelsInSelectors = cc.CommonLabelsInSelectors || newConfig.CommonLabelsInSelectors
+
+	for key, config := range cc.Subcomponents {
+		cc.Subcomponents[key] = config.MergeCommonMetadataFlags(newConfig.Subcomponents[key])
+	}
+
+	return *cc
+}
+
+// Inherit adds the common labels and annotations, the cluster scoped kinds, and the global config of the
+// config of the parent component `parentConfig` which this config does not set, such that they apply to the
+// whole subtree. The global config is then added to the config values this config does not set.
+func (cc *ComponentConfig) Inherit(parentConfig ComponentConfig) {
+	inherit := func(values map[string]string, inherited map[string]string) map[string]string {
+		if len(inherited) == 0 {
+			return values
+		}
+
+		merged := map[string]string{}
+		for key, value := range inherited {
+			merged[key] = value
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+		return merged
+	}
+
+	cc.CommonLabels = inherit(cc.CommonLabels, parentConfig.CommonLabels)
+	cc.CommonAnnotations = inherit(cc.CommonAnnotations, parentConfig.CommonAnnotations)
+	cc.CommonMetadataInTemplates = cc.CommonMetadataInTemplates || parentConfig.CommonMetadataInTemplates
+	cc.CommonLabelsInSelectors = cc.CommonLabelsInSelectors || parentConfig.CommonLabelsInSelectors
+
+	for _, kind := range parentConfig.ClusterScopedKinds {
+		if !containsString(cc.ClusterScopedKinds, kind) {
+			cc.ClusterScopedKinds = append(cc.ClusterScopedKinds, kind)
+		}
+	}
+
+	cc.Global = withDefaults(cc.Global, parentConfig.Global)
+	cc.Config = withDefaults(cc.Config, cc.Global)
+}
+
+// withDefaults returns a copy of the config values `values` with the values of `defaults` they do not set,
+// merging the maps set in both.
+func withDefaults(values map[string]interface{}, defaults map[string]interface{}) map[string]interface{} {
+	if len(defaults) == 0 {
+		return values
+	}
+
+	merged := copyConfigValue(defaults).(map[string]interface{})
+	for key, value := range values {
+		valueMap, isMap := value.(map[string]interface{})
+		defaultMap, isDefaultMap := merged[key].(map[string]interface{})
+		if isMap && isDefaultMap {
+			merged[key] = withDefaults(valueMap, defaultMap)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// containsString returns whether `values` contains `value`.
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Merge merges the config (and the namespace spec) between the passed componentConfig
-// and this componentConfig.  In the case of conflicts, this componentConfig wins.
+// and this componentConfig.  In the case of conflicts, this componentConfig wins; lists are
+// concatenated, but for those merged according to the mergeStrategies of either.
 func (cc *ComponentConfig) Merge(newConfig ComponentConfig) (err error) {
+	lists, err := cc.mergeLists(newConfig)
+	if err != nil {
+		return err
+	}
+
 	options := conjungo.NewOptions()
 	options.Overwrite = false
 
-	err = conjungo.Merge(cc, newConfig, options)
+	if err = conjungo.Merge(cc, newConfig, options); err != nil {
+		return err
+	}
+	for _, list := range lists {
+		if err = cc.SetConfig(list.subcomponentPath, list.path, list.value); err != nil {
+			return err
+		}
+	}
 
 	cc.MergeNamespaces(newConfig)
+	cc.MergeCommonMetadataFlags(newConfig)
+	cc.Encrypted = cc.Encrypted || newConfig.Encrypted
 
 	return err
 }
@@ -204,6 +402,11 @@ func (cc *ComponentConfig) Merge(newConfig ComponentConfig) (err error) {
 // Write writes this componentConfig to a file using the serialization specified in
 // cc.Serialization.
 func (cc *ComponentConfig) Write(environment string) (err error) {
+	// Never write decrypted values, nor the values of encrypted files without their encryption metadata
+	if cc.Encrypted {
+		return fmt.Errorf("config '%s' is encrypted; edit it with `%s` instead", cc.GetPath(environment), SopsExecutable)
+	}
+
 	var marshaledConfig []byte
 
 	_ = os.Mkdir(cc.Path, os.ModePerm)
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AppendIndex is the list index of a `[+]` config path part, appending to the list.
+const AppendIndex = -1
+
+// ListIndex returns the index of a config path part indexing into a list, of the
+// form `[N]` or `[+]` (AppendIndex); ok is false if `pathPart` is a map key.
+func ListIndex(pathPart string) (index int, ok bool) {
+	if len(pathPart) < 3 || !strings.HasPrefix(pathPart, "[") || !strings.HasSuffix(pathPart, "]") {
+		return 0, false
+	}
+
+	inner := pathPart[1 : len(pathPart)-1]
+	if inner == "+" {
+		return AppendIndex, true
+	}
+
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 || strconv.Itoa(index) != inner {
+		return 0, false
+	}
+
+	return index, true
+}
+
+// SplitConfigPath splits a config path at . while ignoring string literals enclosed in quotes ("."),
+// and splits the list indexes off its parts; `ingress.hosts[0]` is split into `ingress`, `hosts`,
+// and `[0]`.
+func SplitConfigPath(path string) (pathParts []string, err error) {
+	csv := csv.NewReader(strings.NewReader(path))
+
+	// Comma is the field delimiter. Dot (.) will be the value for config key
+	csv.Comma = '.'
+
+	// setting it to true, a quote may appear in an unquoted field and a non-doubled quote may appear in a quoted field.
+	csv.LazyQuotes = true
+
+	// FieldsPerRecord is the number of expected fields per record.
+	// > 0: Read requires each record to have the given number of fields.
+	// == 0, Read sets it to the number of fields in the first record, so that future records must have the same field count.
+	// < 0, no check is made and config key may have a variable number of fields.
+	csv.FieldsPerRecord = -1
+
+	parts, err := csv.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// split list indexes (eg. hosts[0] or tolerations[+]) into parts of their own
+	for _, part := range parts {
+		pathParts = append(pathParts, splitListIndexes(part)...)
+	}
+
+	return pathParts, nil
+}
+
+// listIndexSuffix matches a trailing list index of a config path part, eg. the `[0]` of `hosts[0]`
+var listIndexSuffix = regexp.MustCompile(`\[([0-9]+|\+)\]$`)
+
+// splitListIndexes splits the trailing list indexes off a config path part; `hosts[0][1]` is split
+// into `hosts`, `[0]`, and `[1]`.
+func splitListIndexes(part string) []string {
+	indexes := []string{}
+	for {
+		location := listIndexSuffix.FindStringIndex(part)
+		if location == nil || location[0] == 0 {
+			break
+		}
+
+		indexes = append([]string{part[location[0]:]}, indexes...)
+		part = part[:location[0]]
+	}
+
+	return append([]string{part}, indexes...)
+}
+
+// JoinConfigPath joins the parts of a config path, eg. `ingress.hosts[0]`.
+func JoinConfigPath(path []string) string {
+	joined := ""
+	for _, pathPart := range path {
+		if _, isIndex := ListIndex(pathPart); isIndex || joined == "" {
+			joined += pathPart
+		} else {
+			joined += "." + pathPart
+		}
+	}
+
+	return joined
+}
+
+// getConfigValue returns the value at `path` within `configLevel`, descending
+// into maps and lists.
+func getConfigValue(configLevel interface{}, path []string) (value interface{}, ok bool) {
+	value = configLevel
+	for _, pathPart := range path {
+		if index, isIndex := ListIndex(pathPart); isIndex {
+			list, isList := value.([]interface{})
+			if !isList || index == AppendIndex || index >= len(list) {
+				return nil, false
+			}
+			value = list[index]
+			continue
+		}
+
+		configMap, isMap := value.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		if value, ok = configMap[pathPart]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// setConfigValue sets `value` at `path` within `configLevel`, creating the maps
+// and lists on the way. Returns the updated level (appending to a list may
+// reallocate it) and whether any config was created.
+func setConfigValue(configLevel interface{}, path []string, value interface{}) (updated interface{}, created bool, err error) {
+	if len(path) == 0 {
+		return value, false, nil
+	}
+
+	pathPart := path[0]
+	if index, isIndex := ListIndex(pathPart); isIndex {
+		list, isList := configLevel.([]interface{})
+		if !isList && configLevel != nil {
+			return configLevel, false, fmt.Errorf("%s is not a list", pathPart)
+		}
+
+		switch {
+		case index == AppendIndex || index == len(list):
+			list = append(list, nil)
+			index = len(list) - 1
+			created = true
+		case index > len(list):
+			return configLevel, false, fmt.Errorf("index %s is out of range of a list of %d items", pathPart, len(list))
+		}
+
+		item, itemCreated, err := setConfigValue(list[index], path[1:], value)
+		if err != nil {
+			return configLevel, false, err
+		}
+		list[index] = item
+
+		return list, created || itemCreated, nil
+	}
+
+	configMap, isMap := configLevel.(map[string]interface{})
+	if !isMap {
+		if configLevel != nil {
+			return configLevel, false, fmt.Errorf("cannot set key %s of a value which is not a map", pathPart)
+		}
+		configMap = map[string]interface{}{}
+	}
+
+	existing, exists := configMap[pathPart]
+	item, itemCreated, err := setConfigValue(existing, path[1:], value)
+	if err != nil {
+		return configLevel, false, err
+	}
+	configMap[pathPart] = item
+
+	return configMap, !exists || itemCreated, nil
+}
+
+// unsetConfigValue removes the value at `path` from `configLevel` and any maps
+// and lists left empty on the way. Returns the updated level.
+func unsetConfigValue(configLevel interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return configLevel
+	}
+
+	// isEmpty returns whether an item was left empty by the removal
+	isEmpty := func(item interface{}) bool {
+		switch typed := item.(type) {
+		case map[string]interface{}:
+			return len(typed) == 0
+		case []interface{}:
+			return len(typed) == 0
+		}
+		return false
+	}
+
+	switch typed := configLevel.(type) {
+	case map[string]interface{}:
+		item, exists := typed[path[0]]
+		if !exists {
+			return typed
+		}
+
+		if len(path) > 1 {
+			if item = unsetConfigValue(item, path[1:]); !isEmpty(item) {
+				typed[path[0]] = item
+				return typed
+			}
+		}
+		delete(typed, path[0])
+
+		return typed
+	case []interface{}:
+		index, isIndex := ListIndex(path[0])
+		if !isIndex || index == AppendIndex || index >= len(typed) {
+			return typed
+		}
+
+		if len(path) > 1 {
+			if item := unsetConfigValue(typed[index], path[1:]); !isEmpty(item) {
+				typed[index] = item
+				return typed
+			}
+		}
+
+		return append(typed[:index], typed[index+1:]...)
+	}
+
+	return configLevel
+}
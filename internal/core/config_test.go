@@ -91,6 +91,67 @@ func TestSet(t *testing.T) {
 	assert.Equal(t, "fast", dataMap["storageClass"])
 }
 
+func TestUnset(t *testing.T) {
+	config := NewComponentConfig(".")
+	config.SetConfig([]string{}, []string{"foo"}, "fee")
+	config.SetConfig([]string{}, []string{"data", "storageClass"}, "fast")
+	config.SetConfig([]string{}, []string{"data", "size"}, "10Gi")
+	config.SetConfig([]string{"myapp", "mysubapp"}, []string{"zoo", "zii"}, "zaa")
+
+	value, ok := config.GetComponentConfig([]string{"data", "size"})
+	assert.True(t, ok)
+	assert.Equal(t, "10Gi", value)
+	assert.False(t, config.HasComponentConfig([]string{"foo", "bar"}))
+
+	// maps which are not left empty are kept
+	config.UnsetConfig([]string{}, []string{"data", "size"})
+	assert.False(t, config.HasComponentConfig([]string{"data", "size"}))
+	assert.True(t, config.HasComponentConfig([]string{"data", "storageClass"}))
+
+	// empty maps are pruned
+	config.UnsetConfig([]string{}, []string{"data", "storageClass"})
+	assert.False(t, config.HasComponentConfig([]string{"data"}))
+	assert.True(t, config.HasComponentConfig([]string{"foo"}))
+
+	// empty subcomponent configs are pruned
+	config.UnsetConfig([]string{"myapp", "mysubapp"}, []string{"zoo", "zii"})
+	assert.False(t, config.HasSubcomponentConfig([]string{"myapp"}))
+}
+
+func TestSetList(t *testing.T) {
+	config := NewComponentConfig(".")
+
+	// lists are created, appended to, and indexed into
+	assert.Nil(t, config.SetConfig([]string{}, []string{"ingress", "hosts", "[0]"}, "a.example.com"))
+	assert.Nil(t, config.SetConfig([]string{}, []string{"ingress", "hosts", "[+]"}, "b.example.com"))
+	assert.Nil(t, config.SetConfig([]string{}, []string{"ingress", "hosts", "[0]"}, "c.example.com"))
+	assert.Equal(t, []interface{}{"c.example.com", "b.example.com"}, config.Config["ingress"].(map[string]interface{})["hosts"])
+
+	assert.Nil(t, config.SetConfig([]string{"myapp"}, []string{"tolerations", "[+]", "key"}, "dedicated"))
+	assert.Nil(t, config.SetConfig([]string{"myapp"}, []string{"tolerations", "[0]", "operator"}, "Exists"))
+	subcomponentConfig := config.Subcomponents["myapp"]
+	value, ok := subcomponentConfig.GetComponentConfig([]string{"tolerations", "[0]", "operator"})
+	assert.True(t, ok)
+	assert.Equal(t, "Exists", value)
+
+	// out of range indexes and indexing into maps fail
+	assert.NotNil(t, config.SetConfig([]string{}, []string{"ingress", "hosts", "[3]"}, "d.example.com"))
+	assert.NotNil(t, config.SetConfig([]string{}, []string{"ingress", "[0]"}, "d.example.com"))
+	assert.NotNil(t, config.SetConfig([]string{}, []string{"ingress", "hosts", "[0]", "name"}, "d.example.com"))
+
+	// items are removed from lists, and lists left empty are pruned
+	config.UnsetConfig([]string{}, []string{"ingress", "hosts", "[0]"})
+	assert.Equal(t, []interface{}{"b.example.com"}, config.Config["ingress"].(map[string]interface{})["hosts"])
+	config.UnsetConfig([]string{}, []string{"ingress", "hosts", "[0]"})
+	assert.False(t, config.HasComponentConfig([]string{"ingress"}))
+}
+
+func TestJoinConfigPath(t *testing.T) {
+	assert.Equal(t, "ingress.hosts[0]", JoinConfigPath([]string{"ingress", "hosts", "[0]"}))
+	assert.Equal(t, "tolerations[+].key", JoinConfigPath([]string{"tolerations", "[+]", "key"}))
+	assert.Equal(t, "a.[b]", JoinConfigPath([]string{"a", "[b]"}))
+}
+
 func TestWriteYAML(t *testing.T) {
 	_ = os.Remove("../../testdata/write/config/test.yaml")
 
@@ -174,3 +235,100 @@ func TestDisabledTrue(t *testing.T) {
 	elasticsearchDisabled := elasticsearchSubcomponent.Disabled
 	assert.Equal(t, true, elasticsearchDisabled)
 }
+
+func TestWriteEncrypted(t *testing.T) {
+	config := ComponentConfig{
+		Path: "../../testdata/secrets",
+	}
+
+	// The ENC[...] values of an encrypted config file are loaded as is, and never written back
+	err := config.Load("prod")
+	assert.Nil(t, err)
+	assert.True(t, config.Encrypted)
+	assert.True(t, config.HasSubcomponentConfig([]string{"database"}))
+	assert.NotNil(t, config.Write("prod"))
+
+	assert.True(t, IsEncryptedFile("config/prod.enc.yaml", []byte("foo: bar")))
+	assert.False(t, IsEncryptedFile("config/prod.yaml", []byte("password: ENC[AES256_GCM,data:hunter2]")))
+}
+
+func TestMergeStrategies(t *testing.T) {
+	config := NewComponentConfig("../../testdata/merge-strategies")
+	assert.Nil(t, config.MergeConfigFile(config.Path, "prod"))
+	assert.Nil(t, config.MergeConfigFile(config.Path, "common"))
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "dedicated", "operator": "Exists"},
+		map[string]interface{}{"key": "spot", "operator": "Exists"},
+	}, config.Config["tolerations"])
+	assert.Equal(t, []interface{}{"--production", "--verbose"}, config.Config["args"])
+	assert.Equal(t, []interface{}{"west"}, config.Config["zones"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name":  "web",
+			"image": "web:1.1",
+			"env": []interface{}{
+				map[string]interface{}{"name": "LOG_LEVEL", "value": "warn"},
+				map[string]interface{}{"name": "TRACING", "value": "on"},
+				map[string]interface{}{"name": "REGION", "value": "west"},
+			},
+		},
+		map[string]interface{}{"name": "sidecar", "image": "proxy:1.0"},
+	}, config.Config["containers"])
+
+	// Lists without a strategy are concatenated
+	assert.Equal(t, []interface{}{"prod.example.com", "common.example.com"}, config.Config["hosts"])
+
+	// Subcomponent configs are merged with their own strategies
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "LOG_LEVEL", "value": "warn"},
+		map[string]interface{}{"name": "QUEUE", "value": "jobs"},
+	}, config.Subcomponents["worker"].Config["env"])
+
+	// Unknown strategies are reported
+	unknown := NewComponentConfig(".")
+	unknown.MergeStrategies = map[string]string{"tolerations": "union"}
+	assert.EqualError(t, unknown.Merge(NewComponentConfig(".")), "error merging tolerations: unknown merge strategy 'union'; expected replace, append, prepend, or merge-by-key:<key>")
+}
+
+func TestExplainMergeStrategies(t *testing.T) {
+	c := Component{Name: "merge-strategies", PhysicalPath: "../../testdata/merge-strategies", Config: NewComponentConfig("../../testdata/merge-strategies")}
+	assert.Nil(t, c.LoadConfig([]string{"prod"}))
+
+	// Replaced lists shadow those of the config files after them
+	explanation, err := c.ExplainConfig([]string{"zones"})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"west"}, explanation.Value)
+	assert.Equal(t, 2, len(explanation.Sources))
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "shadowed", explanation.Sources[1].Effect)
+
+	// Lists merged by key are merged into
+	explanation, err = c.ExplainConfig([]string{"containers"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(explanation.Value.([]interface{})))
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "merges", explanation.Sources[1].Effect)
+
+	// as are the lists of their items, with the strategies set for them
+	explanation, err = c.ExplainConfig([]string{"containers", "[0]", "env"})
+	assert.Nil(t, err)
+	assert.Equal(t, "../../testdata/merge-strategies/config/prod.yaml", explanation.Sources[0].Source.File)
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "merges", explanation.Sources[1].Effect)
+
+	// Items merged by key are shadowed by those of the config files before them
+	explanation, err = c.ExplainConfig([]string{"containers", "[0]", "image"})
+	assert.Nil(t, err)
+	assert.Equal(t, "web:1.1", explanation.Value)
+	assert.Equal(t, "shadowed", explanation.Sources[1].Effect)
+
+	// Subcomponents are merged with the strategies of the overrides
+	worker := Component{Name: "worker", Config: c.Config.Subcomponents["worker"], ConfigSources: c.subcomponentConfigSources("worker")}
+	explanation, err = worker.ExplainConfig([]string{"env", "[0]", "value"})
+	assert.Nil(t, err)
+	assert.Equal(t, "warn", explanation.Value)
+	assert.Equal(t, 2, len(explanation.Sources))
+	assert.Equal(t, "defines", explanation.Sources[0].Effect)
+	assert.Equal(t, "shadowed", explanation.Sources[1].Effect)
+}
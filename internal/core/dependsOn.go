@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OrderSubcomponents returns `subcomponents` sorted such that every
+// subcomponent comes after the siblings named in its `dependsOn`. Siblings
+// without an ordering constraint between them keep their order in
+// `subcomponents`.
+//
+// Returns an error if a subcomponent depends on a sibling which does not exist
+// or if the dependencies form a cycle.
+func OrderSubcomponents(parentName string, subcomponents []Component) (ordered []Component, err error) {
+	indexes := map[string]int{}
+	for index, subcomponent := range subcomponents {
+		indexes[subcomponent.Name] = index
+	}
+
+	// Count the unresolved dependencies of each subcomponent and track who depends on whom
+	unresolved := make([]int, len(subcomponents))
+	dependents := make([][]int, len(subcomponents))
+	for index, subcomponent := range subcomponents {
+		for _, dependency := range subcomponent.DependsOn {
+			dependencyIndex, exists := indexes[dependency]
+			if !exists {
+				return nil, fmt.Errorf("subcomponent '%s' of component '%s' depends on '%s' which is not a subcomponent of '%s'", subcomponent.Name, parentName, dependency, parentName)
+			}
+			unresolved[index]++
+			dependents[dependencyIndex] = append(dependents[dependencyIndex], index)
+		}
+	}
+
+	// Repeatedly take the first subcomponent with all of its dependencies resolved
+	visited := make([]bool, len(subcomponents))
+	for len(ordered) < len(subcomponents) {
+		next := -1
+		for index := range subcomponents {
+			if !visited[index] && unresolved[index] == 0 {
+				next = index
+				break
+			}
+		}
+		if next == -1 {
+			return nil, fmt.Errorf("subcomponents of component '%s' have a dependency cycle: %s", parentName, strings.Join(findCycle(subcomponents, indexes, visited), " -> "))
+		}
+
+		visited[next] = true
+		ordered = append(ordered, subcomponents[next])
+		for _, dependent := range dependents[next] {
+			unresolved[dependent]--
+		}
+	}
+
+	return ordered, nil
+}
+
+// findCycle returns the names along a dependency cycle amongst the subcomponents
+// not yet `visited`, starting and ending with the same name.
+func findCycle(subcomponents []Component, indexes map[string]int, visited []bool) []string {
+	// Every unvisited subcomponent has an unvisited dependency; following them must loop
+	start := 0
+	for visited[start] {
+		start++
+	}
+
+	seen := map[int]int{}
+	path := []string{}
+	for current := start; ; {
+		if position, exists := seen[current]; exists {
+			return append(path[position:], subcomponents[current].Name)
+		}
+		seen[current] = len(path)
+		path = append(path, subcomponents[current].Name)
+
+		for _, dependency := range subcomponents[current].DependsOn {
+			if !visited[indexes[dependency]] {
+				current = indexes[dependency]
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// reference matches the references interpolated into config values, eg.
+// `${env:CLUSTER_NAME}`; `$${...}` escapes them. Other `${...}`, eg. those of
+// shell scripts or Grafana dashboards, are left as is.
+var reference = regexp.MustCompile(`\$?\$\{((?:env:|config:|component\.)[^{}]*)\}`)
+
+// ConfigResolver interpolates the references of config values, looking up
+// the config referenced with `${config:...}` in the components of a tree.
+type ConfigResolver struct {
+	components map[string]Component // keyed by componentPath
+}
+
+// componentPath returns the path of `c` from the root component, eg. `/app/web`.
+func componentPath(c Component) string {
+	return "/" + strings.Join(c.SubcomponentPath, "/")
+}
+
+// NewConfigResolver returns a resolver looking up config references in
+// `components`, as walked with their config merged.
+func NewConfigResolver(components []Component) *ConfigResolver {
+	resolver := &ConfigResolver{components: map[string]Component{}}
+	for _, component := range components {
+		resolver.components[componentPath(component)] = component
+	}
+
+	return resolver
+}
+
+// Interpolate replaces the references in the config values of `c`:
+//
+//   - `${env:NAME}` with the value of the environment variable NAME
+//   - `${config:ingress.domain}` with the value of a config path of `c`, or
+//     `${config:../ingress.domain}` of another component of the tree, by its
+//     path relative to `c` (or to the root component if starting with `/`)
+//   - `${component.name}` and `${component.namespace}` with those of `c`
+//
+// A value which is a single config reference takes the referenced value as
+// is, maps and lists included; references within a string must be scalars.
+func (r *ConfigResolver) Interpolate(c *Component) error {
+	interpolated, err := r.interpolate(*c, c.Config.Config, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error interpolating config of component '%s': %w", c.Name, err)
+	}
+	c.Config.Config, _ = interpolated.(map[string]interface{})
+
+	return nil
+}
+
+// interpolate returns a copy of `value`, at `configPath` of the config of `c`,
+// with its references replaced. `resolving` are the config values being
+// resolved, to detect cycles.
+func (r *ConfigResolver) interpolate(c Component, value interface{}, configPath []string, resolving []string) (interface{}, error) {
+	// childPath returns the config path of an item of `value`
+	childPath := func(pathPart string) []string {
+		return append(append([]string{}, configPath...), pathPart)
+	}
+
+	switch typed := value.(type) {
+	case string:
+		interpolated, err := r.interpolateString(c, typed, resolving)
+		if err != nil && len(resolving) == 0 {
+			return nil, fmt.Errorf("%s: %w", JoinConfigPath(configPath), err)
+		}
+		return interpolated, err
+	case map[string]interface{}:
+		interpolated := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			interpolatedItem, err := r.interpolate(c, item, childPath(key), resolving)
+			if err != nil {
+				return nil, err
+			}
+			interpolated[key] = interpolatedItem
+		}
+		return interpolated, nil
+	case map[interface{}]interface{}:
+		interpolated := make(map[interface{}]interface{}, len(typed))
+		for key, item := range typed {
+			interpolatedItem, err := r.interpolate(c, item, childPath(fmt.Sprint(key)), resolving)
+			if err != nil {
+				return nil, err
+			}
+			interpolated[key] = interpolatedItem
+		}
+		return interpolated, nil
+	case []interface{}:
+		interpolated := make([]interface{}, len(typed))
+		for index, item := range typed {
+			interpolatedItem, err := r.interpolate(c, item, childPath(fmt.Sprintf("[%d]", index)), resolving)
+			if err != nil {
+				return nil, err
+			}
+			interpolated[index] = interpolatedItem
+		}
+		return interpolated, nil
+	}
+
+	return value, nil
+}
+
+// interpolateString replaces the references of the config value `value` of `c`.
+func (r *ConfigResolver) interpolateString(c Component, value string, resolving []string) (interface{}, error) {
+	matches := reference.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+
+	// A single reference keeps the type of the referenced value
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) && !strings.HasPrefix(value, "$$") {
+		return r.resolve(c, value[matches[0][2]:matches[0][3]], resolving)
+	}
+
+	var interpolated strings.Builder
+	end := 0
+	for _, match := range matches {
+		interpolated.WriteString(value[end:match[0]])
+		end = match[1]
+
+		if strings.HasPrefix(value[match[0]:], "$$") {
+			interpolated.WriteString(value[match[0]+1 : match[1]])
+			continue
+		}
+
+		expression := value[match[2]:match[3]]
+		resolved, err := r.resolve(c, expression, resolving)
+		if err != nil {
+			return nil, err
+		}
+		switch resolved.(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return nil, fmt.Errorf("'${%s}' is a map or a list, which can only be referenced as a whole value", expression)
+		case nil:
+			return nil, fmt.Errorf("'${%s}' is null", expression)
+		}
+		interpolated.WriteString(fmt.Sprint(resolved))
+	}
+	interpolated.WriteString(value[end:])
+
+	return interpolated.String(), nil
+}
+
+// resolve returns the value of the reference `${expression}` in the config of `c`.
+func (r *ConfigResolver) resolve(c Component, expression string, resolving []string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(expression, "env:"):
+		name := strings.TrimPrefix(expression, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable '%s' of '${%s}' is not set", name, expression)
+		}
+		return value, nil
+	case strings.HasPrefix(expression, "config:"):
+		return r.resolveConfig(c, strings.TrimPrefix(expression, "config:"), resolving)
+	case expression == "component.name":
+		return c.Name, nil
+	case expression == "component.namespace":
+		return c.Config.Namespace, nil
+	}
+
+	return nil, fmt.Errorf("unknown reference '${%s}'; expected ${component.name} or ${component.namespace}", expression)
+}
+
+// resolveConfig returns the interpolated config value referenced by
+// `${config:<configReference>}` from `c`.
+func (r *ConfigResolver) resolveConfig(c Component, configReference string, resolving []string) (interface{}, error) {
+	targetPath, keyPath, err := splitConfigReference(componentPath(c), configReference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference '${config:%s}': %w", configReference, err)
+	}
+
+	target, ok := r.components[targetPath]
+	if targetPath == componentPath(c) {
+		target, ok = c, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("component '%s' of '${config:%s}' is not part of the component tree, or is disabled", targetPath, configReference)
+	}
+	pathParts, err := SplitConfigPath(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference '${config:%s}': %w", configReference, err)
+	}
+
+	key := targetPath + ":" + JoinConfigPath(pathParts)
+	for _, resolvingKey := range resolving {
+		if resolvingKey == key {
+			return nil, fmt.Errorf("config references form a cycle: %s -> %s", strings.Join(resolving, " -> "), key)
+		}
+	}
+
+	value, ok := getConfigValue(target.Config.Config, pathParts)
+	if !ok {
+		return nil, fmt.Errorf("'%s' of '${config:%s}' is not set in the config of component '%s'", JoinConfigPath(pathParts), configReference, targetPath)
+	}
+
+	return r.interpolate(target, value, pathParts, append(append([]string{}, resolving...), key))
+}
+
+// splitConfigReference splits a config reference from the component at
+// `from` into the path of the referenced component and the config path within
+// it: `../ingress.domain` from `/app` is split into `/ingress` and `domain`.
+// References without a `/` are to the config of `from` itself.
+func splitConfigReference(from string, configReference string) (targetPath string, keyPath string, err error) {
+	// The component path ends at the last `/` out of quotes
+	separator, quoted := -1, false
+	for index, character := range configReference {
+		switch character {
+		case '"':
+			quoted = !quoted
+		case '/':
+			if !quoted {
+				separator = index
+			}
+		}
+	}
+	if separator == -1 {
+		return from, configReference, validConfigPath(configReference)
+	}
+
+	// followed by the name of the component, up to the first `.`; `/.cluster` is
+	// the `cluster` config of the root component
+	name := configReference[separator+1:]
+	dot := strings.Index(name, ".")
+	if dot < 0 {
+		return "", "", fmt.Errorf("expected a config path after the component path")
+	}
+	keyPath = name[dot+1:]
+	componentReference := configReference[:separator+1] + name[:dot]
+
+	if strings.HasPrefix(componentReference, "/") {
+		targetPath = path.Clean(componentReference)
+	} else {
+		targetPath = path.Join(from, componentReference)
+	}
+
+	return targetPath, keyPath, validConfigPath(keyPath)
+}
+
+// validConfigPath returns an error if `keyPath` is empty.
+func validConfigPath(keyPath string) error {
+	if keyPath == "" {
+		return fmt.Errorf("expected a config path")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolate(t *testing.T) {
+	component := func(config map[string]interface{}, subcomponentPath ...string) Component {
+		c := Component{SubcomponentPath: subcomponentPath, Config: NewComponentConfig(".")}
+		if len(subcomponentPath) > 0 {
+			c.Name = subcomponentPath[len(subcomponentPath)-1]
+		}
+		c.Config.Config = config
+		return c
+	}
+	root := component(map[string]interface{}{
+		"replicas": 3,
+		"hosts":    []interface{}{"a.example.com", "b.example.com"},
+	})
+	ingress := component(map[string]interface{}{
+		"domain": "example.com",
+		"a":      "${config:b}",
+		"b":      "${config:../app.a}",
+	}, "ingress")
+	app := component(map[string]interface{}{
+		"a": "${config:../ingress.a}",
+	}, "app")
+	resolver := NewConfigResolver([]Component{root, ingress, app})
+
+	interpolated := func(config map[string]interface{}) (map[string]interface{}, error) {
+		c := component(config, "app", "web")
+		c.Config.Namespace = "web"
+		err := resolver.Interpolate(&c)
+		return c.Config.Config, err
+	}
+
+	config, err := interpolated(map[string]interface{}{
+		"host":     "${component.name}.${config:../../ingress.domain}",
+		"replicas": "${config:/.replicas}",
+		"hosts":    []interface{}{"${config:/.hosts[1]}", "$${config:escaped}"},
+		"labels":   map[string]interface{}{"namespace": "${component.namespace}", "self": "${config:host}"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"host":     "web.example.com",
+		"replicas": 3,
+		"hosts":    []interface{}{"b.example.com", "${config:escaped}"},
+		"labels":   map[string]interface{}{"namespace": "web", "self": "web.example.com"},
+	}, config)
+
+	// Failures point at the failing config value
+	_, err = interpolated(map[string]interface{}{"a": "${config:../../ingress.a}"})
+	assert.EqualError(t, err, "error interpolating config of component 'web': a: config references form a cycle: /ingress:a -> /ingress:b -> /app:a -> /ingress:a")
+	_, err = interpolated(map[string]interface{}{"hosts": "hosts: ${config:/.hosts}"})
+	assert.EqualError(t, err, "error interpolating config of component 'web': hosts: '${config:/.hosts}' is a map or a list, which can only be referenced as a whole value")
+	_, err = interpolated(map[string]interface{}{"domain": "${config:../cache.domain}"})
+	assert.EqualError(t, err, "error interpolating config of component 'web': domain: component '/app/cache' of '${config:../cache.domain}' is not part of the component tree, or is disabled")
+	_, err = interpolated(map[string]interface{}{"domain": "${config:../../ingress.dns}"})
+	assert.EqualError(t, err, "error interpolating config of component 'web': domain: 'dns' of '${config:../../ingress.dns}' is not set in the config of component '/ingress'")
+	_, err = interpolated(map[string]interface{}{"domain": "${component.domain}"})
+	assert.EqualError(t, err, "error interpolating config of component 'web': domain: unknown reference '${component.domain}'; expected ${component.name} or ${component.namespace}")
+
+	// `${...}` meant for other tools are left as is
+	config, err = interpolated(map[string]interface{}{
+		"script":     "echo ${FOO} > ${HOME}/foo",
+		"datasource": "${DS_PROMETHEUS}",
+		"escaped":    "$${FOO}",
+		"mixed":      "${FOO}.${component.name}",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"script":     "echo ${FOO} > ${HOME}/foo",
+		"datasource": "${DS_PROMETHEUS}",
+		"escaped":    "$${FOO}",
+		"mixed":      "${FOO}.web",
+	}, config)
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/git"
+	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/timfpark/yaml"
+)
+
+// LockFilename is the name of the file `install` records the resolved sources
+// of a definition to. It lives next to the root component.yaml.
+const LockFilename = "fab.lock"
+
+// GitLock records the commit a git sourced component resolved to.
+type GitLock struct {
+	Source  string `yaml:"source" json:"source"`
+	Branch  string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	Commit  string `yaml:"commit" json:"commit"`
+}
+
+// HelmLock records the chart version and archive digest a helm sourced
+// component resolved to.
+type HelmLock struct {
+	Source   string `yaml:"source" json:"source"`
+	Chart    string `yaml:"chart" json:"chart"`
+	Version  string `yaml:"version,omitempty" json:"version,omitempty"`
+	Resolved string `yaml:"resolved" json:"resolved"`
+	Digest   string `yaml:"digest" json:"digest"`
+}
+
+// HTTPLock records the content hash of a static component downloaded over http.
+type HTTPLock struct {
+	Source string `yaml:"source" json:"source"`
+	Digest string `yaml:"digest" json:"digest"`
+}
+
+// LockFile documentation: https://github.com/microsoft/fabrikate/blob/master/docs/commands.md#install
+//
+// Entries are keyed by what a component requested (source, branch, version,
+// chart) and record what that request resolved to.
+type LockFile struct {
+	Git  []GitLock  `yaml:"git,omitempty" json:"git,omitempty"`
+	Helm []HelmLock `yaml:"helm,omitempty" json:"helm,omitempty"`
+	HTTP []HTTPLock `yaml:"http,omitempty" json:"http,omitempty"`
+}
+
+// LoadLockFile reads the lock file at `path`. A missing lock file is not an
+// error; an empty LockFile is returned instead.
+func LoadLockFile(path string) (lockFile LockFile, err error) {
+	if err = UnmarshalFile(path, yaml.Unmarshal, &lockFile); os.IsNotExist(err) {
+		return LockFile{}, nil
+	}
+
+	return lockFile, err
+}
+
+// Write serializes the lock file to YAML at `path`, sorting the entries so
+// identical installs produce identical lock files.
+func (l LockFile) Write(path string) error {
+	sort.Slice(l.Git, func(i, j int) bool {
+		return gitLockKey(l.Git[i].Source, l.Git[i].Branch, l.Git[i].Version) < gitLockKey(l.Git[j].Source, l.Git[j].Branch, l.Git[j].Version)
+	})
+	sort.Slice(l.Helm, func(i, j int) bool {
+		return helmLockKey(l.Helm[i].Source, l.Helm[i].Chart, l.Helm[i].Version) < helmLockKey(l.Helm[j].Source, l.Helm[j].Chart, l.Helm[j].Version)
+	})
+	sort.Slice(l.HTTP, func(i, j int) bool {
+		return l.HTTP[i].Source < l.HTTP[j].Source
+	})
+
+	marshaledLockFile, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	logger.Info(emoji.Sprintf(":lock: Writing '%s'", path))
+
+	return ioutil.WriteFile(path, marshaledLockFile, 0644)
+}
+
+func gitLockKey(source, branch, version string) string {
+	return source + "@" + branch + ":" + version
+}
+
+func helmLockKey(source, chart, version string) string {
+	return source + "/" + chart + "@" + version
+}
+
+// Thread safe store of the lock file read at the start of an install and the
+// sources resolved during it.
+type lockStore struct {
+	mu       sync.RWMutex
+	locked   LockFile
+	resolved LockFile
+	update   bool
+}
+
+// Reset replaces the locked sources with `locked` and clears any resolved
+// sources. When `update` is true, locked sources are ignored and every
+// component resolves to its latest matching source.
+func (s *lockStore) Reset(locked LockFile, update bool) {
+	s.mu.Lock()
+	s.locked = locked
+	s.resolved = LockFile{}
+	s.update = update
+	s.mu.Unlock()
+}
+
+// Resolved returns the sources resolved since the last Reset.
+func (s *lockStore) Resolved() LockFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return LockFile{
+		Git:  append([]GitLock{}, s.resolved.Git...),
+		Helm: append([]HelmLock{}, s.resolved.Helm...),
+		HTTP: append([]HTTPLock{}, s.resolved.HTTP...),
+	}
+}
+
+// Git returns the locked entry for a git source, if any.
+func (s *lockStore) Git(source, branch, version string) (GitLock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.update {
+		for _, entry := range s.locked.Git {
+			if gitLockKey(entry.Source, entry.Branch, entry.Version) == gitLockKey(source, branch, version) {
+				return entry, true
+			}
+		}
+	}
+
+	return GitLock{}, false
+}
+
+// RecordGit records the commit a git source resolved to.
+func (s *lockStore) RecordGit(entry GitLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.resolved.Git {
+		if gitLockKey(existing.Source, existing.Branch, existing.Version) == gitLockKey(entry.Source, entry.Branch, entry.Version) {
+			return
+		}
+	}
+	s.resolved.Git = append(s.resolved.Git, entry)
+}
+
+// Helm returns the locked entry for a helm chart, if any.
+func (s *lockStore) Helm(source, chart, version string) (HelmLock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.update {
+		for _, entry := range s.locked.Helm {
+			if helmLockKey(entry.Source, entry.Chart, entry.Version) == helmLockKey(source, chart, version) {
+				return entry, true
+			}
+		}
+	}
+
+	return HelmLock{}, false
+}
+
+// RecordHelm records the chart version and digest a helm chart resolved to.
+func (s *lockStore) RecordHelm(entry HelmLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.resolved.Helm {
+		if helmLockKey(existing.Source, existing.Chart, existing.Version) == helmLockKey(entry.Source, entry.Chart, entry.Version) {
+			return
+		}
+	}
+	s.resolved.Helm = append(s.resolved.Helm, entry)
+}
+
+// HTTP returns the locked entry for a http source, if any.
+func (s *lockStore) HTTP(source string) (HTTPLock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.update {
+		for _, entry := range s.locked.HTTP {
+			if entry.Source == source {
+				return entry, true
+			}
+		}
+	}
+
+	return HTTPLock{}, false
+}
+
+// RecordHTTP records the content hash a http source resolved to.
+func (s *lockStore) RecordHTTP(entry HTTPLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.resolved.HTTP {
+		if existing.Source == entry.Source {
+			return
+		}
+	}
+	s.resolved.HTTP = append(s.resolved.HTTP, entry)
+}
+
+// InstallLock is a thread-safe global store of the locked and resolved sources
+// of the install in progress.
+var InstallLock = lockStore{}
+
+// CloneLocked clones the git repository specified by `opts`, checking out the
+// locked commit if the source is present in InstallLock, and records the commit
+// the clone resolved to. When Offline, the clone is only copied from the install
+// cache.
+func CloneLocked(opts git.CloneOpts) (err error) {
+	opts.Offline = Offline.Enabled()
+	requestedVersion := opts.SHA
+	if entry, ok := InstallLock.Git(opts.URL, opts.Branch, requestedVersion); ok {
+		logger.Info(emoji.Sprintf(":lock: Using locked commit '%s' for '%s'", entry.Commit, opts.URL))
+		opts.SHA = entry.Commit
+	}
+
+	commit, err := git.Clone(&opts)
+	if err != nil {
+		return err
+	}
+
+	InstallLock.RecordGit(GitLock{
+		Source:  opts.URL,
+		Branch:  opts.Branch,
+		Version: requestedVersion,
+		Commit:  commit,
+	})
+
+	return nil
+}
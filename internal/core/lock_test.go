@@ -0,0 +1,82 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFileRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	lockPath := path.Join(dir, LockFilename)
+
+	// A missing lock file loads as an empty one
+	lockFile, err := LoadLockFile(lockPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(lockFile.Git))
+
+	lockFile = LockFile{
+		Git: []GitLock{
+			{Source: "https://github.com/microsoft/zoo", Commit: "b"},
+			{Source: "https://github.com/microsoft/abc", Branch: "master", Commit: "a"},
+		},
+		Helm: []HelmLock{
+			{Source: "https://charts.example.com", Chart: "grafana", Resolved: "1.0.0", Digest: "sha256:1234"},
+		},
+		HTTP: []HTTPLock{
+			{Source: "https://example.com/manifest.yaml", Digest: "sha256:5678"},
+		},
+	}
+	assert.Nil(t, lockFile.Write(lockPath))
+
+	loaded, err := LoadLockFile(lockPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(loaded.Git))
+	assert.Equal(t, "https://github.com/microsoft/abc", loaded.Git[0].Source)
+	assert.Equal(t, "a", loaded.Git[0].Commit)
+	assert.Equal(t, "1.0.0", loaded.Helm[0].Resolved)
+	assert.Equal(t, "sha256:5678", loaded.HTTP[0].Digest)
+}
+
+func TestInstallLock(t *testing.T) {
+	store := lockStore{}
+	store.Reset(LockFile{
+		Git: []GitLock{
+			{Source: "https://github.com/microsoft/abc", Branch: "master", Commit: "a"},
+		},
+		Helm: []HelmLock{
+			{Source: "https://charts.example.com", Chart: "grafana", Resolved: "1.0.0", Digest: "sha256:1234"},
+		},
+	}, false)
+
+	entry, ok := store.Git("https://github.com/microsoft/abc", "master", "")
+	assert.True(t, ok)
+	assert.Equal(t, "a", entry.Commit)
+
+	// Requests for another branch or version are not locked
+	_, ok = store.Git("https://github.com/microsoft/abc", "develop", "")
+	assert.False(t, ok)
+	_, ok = store.Helm("https://charts.example.com", "grafana", "2.0.0")
+	assert.False(t, ok)
+
+	// Recording the same source twice only records it once
+	store.RecordGit(GitLock{Source: "https://github.com/microsoft/abc", Branch: "master", Commit: "a"})
+	store.RecordGit(GitLock{Source: "https://github.com/microsoft/abc", Branch: "master", Commit: "a"})
+	assert.Equal(t, 1, len(store.Resolved().Git))
+
+	// Updating ignores the locked sources
+	store.Reset(LockFile{
+		Git: []GitLock{
+			{Source: "https://github.com/microsoft/abc", Branch: "master", Commit: "a"},
+		},
+	}, true)
+	_, ok = store.Git("https://github.com/microsoft/abc", "master", "")
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(store.Resolved().Git))
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Strategies merging the lists of config values set by several config files; without one, the
+// lists are concatenated, that of the config file with precedence first.
+const (
+	MergeReplace    = "replace"      // the list of the config file with precedence replaces the other
+	MergeAppend     = "append"       // the list of the config file with precedence is appended to the other
+	MergePrepend    = "prepend"      // the list of the config file with precedence is prepended to the other
+	MergeByKey      = "merge-by-key" // items with the same value of a key are merged; `merge-by-key:<key>`, by `name` if omitted
+	defaultMergeKey = "name"
+)
+
+// parseMergeStrategy returns the strategy and, for MergeByKey, the key of
+// `strategy` as set in `mergeStrategies`.
+func parseMergeStrategy(strategy string) (name string, key string, err error) {
+	name = strategy
+	if index := strings.Index(strategy, ":"); index != -1 {
+		name, key = strategy[:index], strategy[index+1:]
+	}
+
+	switch {
+	case name == MergeByKey && key == "" && !strings.Contains(strategy, ":"):
+		return name, defaultMergeKey, nil
+	case name == MergeByKey && key != "":
+		return name, key, nil
+	case (name == MergeReplace || name == MergeAppend || name == MergePrepend) && name == strategy:
+		return name, "", nil
+	}
+
+	return "", "", fmt.Errorf("unknown merge strategy '%s'; expected %s, %s, %s, or %s:<key>", strategy, MergeReplace, MergeAppend, MergePrepend, MergeByKey)
+}
+
+// mergedList is a list of config values merged according to a merge strategy.
+type mergedList struct {
+	subcomponentPath []string
+	path             []string
+	value            []interface{}
+}
+
+// mergeLists returns the lists of this config and `newConfig` at the paths of
+// their `mergeStrategies` (those of this config win) merged according to them,
+// to be set once the rest of the config is merged. Subcomponent configs are
+// merged likewise.
+func (cc *ComponentConfig) mergeLists(newConfig ComponentConfig) (lists []mergedList, err error) {
+	strategies := map[string]string{}
+	for _, configStrategies := range []map[string]string{newConfig.MergeStrategies, cc.MergeStrategies} {
+		for configPath, strategy := range configStrategies {
+			strategies[configPath] = strategy
+		}
+	}
+
+	configPaths := []string{}
+	for configPath := range strategies {
+		configPaths = append(configPaths, configPath)
+	}
+	sort.Strings(configPaths)
+
+	for _, configPath := range configPaths {
+		strategy, key, err := parseMergeStrategy(strategies[configPath])
+		if err != nil {
+			return nil, fmt.Errorf("error merging %s: %w", configPath, err)
+		}
+		pathParts, err := SplitConfigPath(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("error merging %s: %w", configPath, err)
+		}
+
+		value, _ := getConfigValue(cc.Config, pathParts)
+		newValue, _ := getConfigValue(newConfig.Config, pathParts)
+		list, isList := value.([]interface{})
+		newList, isNewList := newValue.([]interface{})
+		if !isList || !isNewList {
+			continue
+		}
+
+		lists = append(lists, mergedList{path: pathParts, value: mergeList(list, newList, strategy, key)})
+	}
+
+	for name, subcomponentConfig := range cc.Subcomponents {
+		newSubcomponentConfig, ok := newConfig.Subcomponents[name]
+		if !ok {
+			continue
+		}
+		subcomponentLists, err := subcomponentConfig.mergeLists(newSubcomponentConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error merging config of subcomponent '%s': %w", name, err)
+		}
+		for _, subcomponentList := range subcomponentLists {
+			subcomponentList.subcomponentPath = append([]string{name}, subcomponentList.subcomponentPath...)
+			lists = append(lists, subcomponentList)
+		}
+	}
+
+	return lists, nil
+}
+
+// mergeList merges `list`, of the config with precedence, with `newList` with
+// `strategy`. Merging by key keeps the order of `newList`, merges the items of
+// `list` into those with the same key, and appends the others.
+func mergeList(list []interface{}, newList []interface{}, strategy string, key string) []interface{} {
+	switch strategy {
+	case MergeAppend:
+		return append(append([]interface{}{}, newList...), list...)
+	case MergePrepend:
+		return append(append([]interface{}{}, list...), newList...)
+	case MergeByKey:
+		// keyOf returns the value of `key` of a list item, if it is a map setting it
+		keyOf := func(item interface{}) (interface{}, bool) {
+			itemMap, isMap := item.(map[string]interface{})
+			if !isMap {
+				return nil, false
+			}
+			value, ok := itemMap[key]
+			return value, ok
+		}
+
+		merged := append([]interface{}{}, newList...)
+		for _, item := range list {
+			itemKey, hasKey := keyOf(item)
+			matched := false
+			for index, newItem := range merged {
+				if newItemKey, newHasKey := keyOf(newItem); hasKey && newHasKey && reflect.DeepEqual(newItemKey, itemKey) {
+					merged[index] = withDefaults(item.(map[string]interface{}), newItem.(map[string]interface{}))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				merged = append(merged, item)
+			}
+		}
+		return merged
+	}
+
+	return list
+}
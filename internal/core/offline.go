@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/microsoft/fabrikate/internal/logger"
+)
+
+// Thread safe store of whether Fabrikate may access the network and of the
+// sources an offline install could not satisfy locally.
+type offlineStore struct {
+	mu      sync.RWMutex
+	enabled bool
+	missing []string
+}
+
+// Enable sets whether Fabrikate runs offline.
+func (s *offlineStore) Enable(enabled bool) {
+	s.mu.Lock()
+	s.enabled = enabled
+	s.mu.Unlock()
+}
+
+// Enabled returns whether Fabrikate runs offline.
+func (s *offlineStore) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.enabled
+}
+
+// Reset clears the sources recorded as missing.
+func (s *offlineStore) Reset() {
+	s.mu.Lock()
+	s.missing = nil
+	s.mu.Unlock()
+}
+
+// RecordMissing records a source which could not be satisfied offline.
+func (s *offlineStore) RecordMissing(source string) {
+	s.mu.Lock()
+	s.missing = append(s.missing, source)
+	s.mu.Unlock()
+}
+
+// Err returns an error listing every source recorded as missing since the
+// last Reset, or nil if none were.
+func (s *offlineStore) Err() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.missing) == 0 {
+		return nil
+	}
+
+	missing := append([]string{}, s.missing...)
+	sort.Strings(missing)
+
+	return fmt.Errorf("offline install could not find the following sources in the install cache or previously installed components; run `fab install` with network access to cache them:\n  - %s", strings.Join(missing, "\n  - "))
+}
+
+// Offline is a thread-safe global store of the offline state of Fabrikate.
+var Offline = offlineStore{}
+
+// InstallOffline satisfies the install of the source described by
+// `description` without network access. `fromCache` is attempted first and is
+// expected to return an error wrapping cache.ErrNotCached if the source is not
+// in the install cache; if so, a previous install at `installPath` is used.
+// Sources found in neither are recorded as missing in Offline.
+func InstallOffline(description string, installPath string, fromCache func() error) error {
+	err := fromCache()
+	if !errors.Is(err, cache.ErrNotCached) {
+		return err
+	}
+
+	if installed, _ := ioutil.ReadDir(installPath); len(installed) > 0 {
+		logger.Info(emoji.Sprintf(":package: Offline: using previously installed %s in '%s'", description, installPath))
+		return nil
+	}
+
+	logger.Warn(emoji.Sprintf(":no_entry_sign: Offline: %s is not in the install cache or previously installed in '%s'", description, installPath))
+	Offline.RecordMissing(description)
+
+	return nil
+}
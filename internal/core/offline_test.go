@@ -0,0 +1,49 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	notCached := func() error {
+		return fmt.Errorf("%w: repository 'https://github.com/microsoft/abc'", cache.ErrNotCached)
+	}
+
+	Offline.Reset()
+	defer Offline.Reset()
+
+	// Sources found in the cache are not missing
+	assert.Nil(t, InstallOffline("cached", path.Join(dir, "cached"), func() error { return nil }))
+	assert.Nil(t, Offline.Err())
+
+	// Other errors are returned as is
+	otherErr := errors.New("checkout failed")
+	assert.Equal(t, otherErr, InstallOffline("broken", path.Join(dir, "broken"), func() error { return otherErr }))
+	assert.Nil(t, Offline.Err())
+
+	// Previously installed sources are reused
+	installedPath := path.Join(dir, "installed")
+	assert.Nil(t, os.MkdirAll(installedPath, 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(installedPath, "component.yaml"), []byte("name: installed\n"), 0644))
+	assert.Nil(t, InstallOffline("installed", installedPath, notCached))
+	assert.Nil(t, Offline.Err())
+
+	// Sources in neither are all reported as missing
+	assert.Nil(t, InstallOffline("repository b", path.Join(dir, "b"), notCached))
+	assert.Nil(t, InstallOffline("repository a", path.Join(dir, "a"), notCached))
+	err = Offline.Err()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "  - repository a\n  - repository b")
+}
@@ -4,5 +4,5 @@ package core
 // Used during the 'set' command to store parsed config paths and values.
 type PathValuePair struct {
 	Path  []string
-	Value string
+	Value interface{}
 }
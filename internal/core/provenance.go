@@ -0,0 +1,201 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+
+	yaml "github.com/timfpark/yaml"
+)
+
+// ConfigSource is a config file contributing to the config of a component:
+// either one of the component's own config files or one of an ancestor
+// whose `subcomponents` section overrides the config of the component.
+type ConfigSource struct {
+	File          string   // path of the config file
+	Component     string   // name of the component the config file belongs to
+	Subcomponents []string // `subcomponents` entries leading from the config in File to that of the component
+	Global        bool     // whether the source is the `global` section of the config, rather than `config`
+}
+
+// IsOverride returns whether the source is a config file of an ancestor.
+func (cs ConfigSource) IsOverride() bool {
+	return len(cs.Subcomponents) > 0
+}
+
+// KeyPath returns the path of the config value at `configPath` within the
+// config file, eg. `subcomponents.a.config.data.replicas`.
+func (cs ConfigSource) KeyPath(configPath []string) string {
+	keys := []string{}
+	for _, subcomponent := range cs.Subcomponents {
+		keys = append(keys, "subcomponents", subcomponent)
+	}
+	section := "config"
+	if cs.Global {
+		section = "global"
+	}
+	keys = append(keys, section)
+	keys = append(keys, configPath...)
+
+	return JoinConfigPath(keys)
+}
+
+// Load loads the config file and returns the config it holds for the
+// component; empty if it holds none.
+func (cs ConfigSource) Load() (config ComponentConfig, err error) {
+	config = NewComponentConfig(path.Dir(cs.File))
+	unmarshal := yaml.Unmarshal
+	if path.Ext(cs.File) == ".json" {
+		unmarshal = json.Unmarshal
+	}
+	if err = UnmarshalFile(cs.File, unmarshal, &config); err != nil {
+		return config, err
+	}
+
+	for _, subcomponent := range cs.Subcomponents {
+		config = config.Subcomponents[subcomponent]
+	}
+
+	return config, nil
+}
+
+// value returns the value the source sets at `configPath` of `config`, as
+// loaded with Load.
+func (cs ConfigSource) value(config ComponentConfig, configPath []string) (value interface{}, ok bool) {
+	if cs.Global {
+		return getConfigValue(config.Global, configPath)
+	}
+
+	return config.GetComponentConfig(configPath)
+}
+
+// configFilePath returns the path of the config file of `environment` for
+// the component at `componentPath` (see ComponentConfig.Load); empty if the
+// component has none.
+func configFilePath(componentPath string, environment string) string {
+	for _, serialization := range []string{"yaml", "json"} {
+		config := ComponentConfig{Path: componentPath, Serialization: serialization}
+		if _, err := os.Stat(config.GetPath(environment)); err == nil {
+			return config.GetPath(environment)
+		}
+	}
+
+	return ""
+}
+
+// subcomponentConfigSources returns the config sources of the component
+// which override the config of its subcomponent `name`.
+func (c *Component) subcomponentConfigSources(name string) (sources []ConfigSource) {
+	for _, source := range c.ConfigSources {
+		subcomponents := append(append([]string{}, source.Subcomponents...), name)
+		sources = append(sources, ConfigSource{File: source.File, Component: source.Component, Subcomponents: subcomponents})
+	}
+
+	return sources
+}
+
+// inheritGlobalSources sets the sources of the global config of the component:
+// the `global` sections of its config sources, then those of its ancestors
+// (those of `parent`).
+func (c *Component) inheritGlobalSources(parent Component) {
+	sources := []ConfigSource{}
+	for _, source := range c.ConfigSources {
+		source.Global = true
+		sources = append(sources, source)
+	}
+
+	c.GlobalSources = append([][]ConfigSource{sources}, parent.GlobalSources...)
+}
+
+// ConfigValueSource is the value a ConfigSource sets for a config path and
+// how it affects the merged value: one of `defines` (the first source setting
+// it), `merges` (adds to the value of the sources before it), or `shadowed`
+// (does not change the merged value).
+type ConfigValueSource struct {
+	Source ConfigSource
+	Value  interface{}
+	Effect string
+}
+
+// ConfigExplanation is the merged value of a config path of a component and
+// the sources setting it, in order of precedence.
+type ConfigExplanation struct {
+	Component string
+	Path      []string
+	Value     interface{}
+	Set       bool
+	Sources   []ConfigValueSource
+}
+
+// ExplainConfig returns the merged value of the config at `configPath` and
+// the config sources which set it, in order of precedence: the config of the
+// component, then the global config of the component and of its ancestors.
+func (c *Component) ExplainConfig(configPath []string) (explanation ConfigExplanation, err error) {
+	explanation = ConfigExplanation{Component: c.Name, Path: configPath}
+	explanation.Value, explanation.Set = c.Config.GetComponentConfig(configPath)
+
+	// Replay the merge of the sources setting the value to tell which of them affect it: the
+	// config files of each level are merged, with the merge strategies in effect, and each
+	// level only adds what those before it do not set (see ComponentConfig.Inherit)
+	merged := map[string]interface{}{}
+	for _, sources := range append([][]ConfigSource{c.ConfigSources}, c.GlobalSources...) {
+		level := ComponentConfig{}
+		for _, source := range sources {
+			config, err := source.Load()
+			if err != nil {
+				return explanation, err
+			}
+			sourceConfig := ComponentConfig{Config: config.Config, MergeStrategies: config.MergeStrategies}
+			if source.Global {
+				sourceConfig = ComponentConfig{Config: config.Global}
+			}
+			sourceConfig.Config, _ = copyConfigValue(sourceConfig.Config).(map[string]interface{})
+
+			before, definedBefore := getConfigValue(withDefaults(merged, level.Config), configPath)
+			before = copyConfigValue(before)
+			if err := level.Merge(sourceConfig); err != nil {
+				return explanation, err
+			}
+
+			// Sources not setting the value are merged all the same, as list items are merged by key
+			value, ok := source.value(config, configPath)
+			if !ok {
+				continue
+			}
+			after, _ := getConfigValue(withDefaults(merged, level.Config), configPath)
+
+			effect := "merges"
+			if reflect.DeepEqual(before, after) {
+				effect = "shadowed"
+			} else if !definedBefore {
+				effect = "defines"
+			}
+			explanation.Sources = append(explanation.Sources, ConfigValueSource{Source: source, Value: value, Effect: effect})
+		}
+		merged, _ = copyConfigValue(withDefaults(merged, level.Config)).(map[string]interface{})
+	}
+
+	return explanation, nil
+}
+
+// copyConfigValue deep copies the maps and lists of a config value; merging
+// modifies them in place.
+func copyConfigValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		copied := map[string]interface{}{}
+		for key, item := range typed {
+			copied[key] = copyConfigValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for index, item := range typed {
+			copied[index] = copyConfigValue(item)
+		}
+		return copied
+	}
+
+	return value
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/logger"
+	"github.com/microsoft/fabrikate/util"
+	yaml "github.com/timfpark/yaml"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// configSchemaFiles are the conventional paths, relative to a component, of the
+// JSON schema its config has to match.
+var configSchemaFiles = []string{"config/schema.yaml", "config/schema.json"}
+
+// ConfigSchemaProvider is implemented by generators which know of a JSON schema
+// the config of a component has to match, eg. the values.schema.json of a helm chart.
+type ConfigSchemaProvider interface {
+	// ConfigSchema returns the path of the schema, empty if there is none, and
+	// the defaults the config of the component is merged onto before validating it.
+	ConfigSchema(component *Component) (schemaPath string, defaults map[string]interface{}, err error)
+}
+
+// hasOwnDefinition returns whether the component was loaded from its own
+// component.yaml/json in PhysicalPath, rather than inlined in its parent.
+func (c *Component) hasOwnDefinition() bool {
+	return c.Serialization != ""
+}
+
+// configSchemaPath returns the path of the JSON schema declared with
+// `configSchema` or shipped at config/schema.yaml (or .json); empty if none.
+func (c *Component) configSchemaPath() string {
+	if c.ConfigSchema != "" {
+		if filepath.IsAbs(c.ConfigSchema) {
+			return c.ConfigSchema
+		}
+		return path.Join(c.PhysicalPath, c.ConfigSchema)
+	}
+
+	// The conventional schema of an inlined component belongs to its parent
+	if !c.hasOwnDefinition() {
+		return ""
+	}
+
+	for _, schemaFile := range configSchemaFiles {
+		schemaPath := path.Join(c.PhysicalPath, schemaFile)
+		if _, err := os.Stat(schemaPath); err == nil {
+			return schemaPath
+		}
+	}
+
+	return ""
+}
+
+// loadSchema loads the JSON schema at `schemaPath`; JSON schemas by reference
+// such that relative `$ref`s resolve, YAML ones by value.
+func loadSchema(schemaPath string) (*gojsonschema.Schema, error) {
+	var loader gojsonschema.JSONLoader
+	if ext := strings.ToLower(path.Ext(schemaPath)); ext == ".yaml" || ext == ".yml" {
+		schema := map[string]interface{}{}
+		if err := UnmarshalFile(schemaPath, yaml.Unmarshal, &schema); err != nil {
+			return nil, err
+		}
+		loader = gojsonschema.NewGoLoader(schema)
+	} else {
+		absSchemaPath, err := filepath.Abs(schemaPath)
+		if err != nil {
+			return nil, err
+		}
+		loader = gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(absSchemaPath))
+	}
+
+	schema, err := gojsonschema.NewSchema(loader)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config schema '%s': %w", schemaPath, err)
+	}
+
+	return schema, nil
+}
+
+// ValidateConfig validates the merged config of the component against the JSON
+// schema declared with `configSchema` or shipped at config/schema.yaml, and the
+// schema provided by `generator` (if it is a ConfigSchemaProvider).
+//
+// Returns an error listing every violation along with the config files setting
+// the offending values. Keys set only by the global config are not reported as
+// additional properties: the global config is added to every component below.
+func (c *Component) ValidateConfig(generator Generator) (err error) {
+	if schemaPath := c.configSchemaPath(); schemaPath != "" {
+		if err := c.validateConfigAgainst(schemaPath, nil); err != nil {
+			return err
+		}
+	}
+
+	if provider, ok := generator.(ConfigSchemaProvider); ok {
+		schemaPath, defaults, err := provider.ConfigSchema(c)
+		if err != nil {
+			return err
+		}
+		if schemaPath != "" {
+			return c.validateConfigAgainst(schemaPath, defaults)
+		}
+	}
+
+	return nil
+}
+
+// validateConfigAgainst validates the merged config of the component, merged
+// onto `defaults`, against the JSON schema at `schemaPath`.
+func (c *Component) validateConfigAgainst(schemaPath string, defaults map[string]interface{}) error {
+	logger.Info(emoji.Sprintf(":microscope: Validating config of component '%s' against schema '%s'", c.Name, schemaPath))
+	schema, err := loadSchema(schemaPath)
+	if err != nil {
+		return err
+	}
+
+	config := c.Config.Config
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	if defaults != nil {
+		config = util.MergeMap(defaults, config)
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(config))
+	if err != nil {
+		return fmt.Errorf("error validating config of component '%s' against schema '%s': %w", c.Name, schemaPath, err)
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	violations := []string{}
+	for _, resultError := range result.Errors() {
+		configPath := schemaFieldPath(config, resultError.Field())
+		if property, ok := resultError.Details()["property"].(string); ok && resultError.Type() == "additional_property_not_allowed" {
+			// The global config is added to the config of every component below it, whether its schema
+			// knows of it or not; only the properties set for the component itself are not allowed
+			configPath = append(configPath, property)
+			if c.setOnlyByGlobal(configPath) {
+				continue
+			}
+		}
+
+		violation := fmt.Sprintf("%s: %s", JoinConfigPath(configPath), resultError.Description())
+		if len(configPath) == 0 {
+			violation = resultError.Description()
+		}
+		if files := c.configFilesSetting(configPath); len(files) > 0 {
+			violation = fmt.Sprintf("%s (set in %s)", violation, strings.Join(files, ", "))
+		}
+		violations = append(violations, violation)
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("config of component '%s' at '%s' does not match schema '%s':\n  - %s", c.Name, c.LogicalPath, schemaPath, strings.Join(violations, "\n  - "))
+}
+
+// configFilesSetting returns the config files which set the merged value at
+// `configPath`; empty if none do (eg. it comes from defaults) or they cannot be told.
+func (c *Component) configFilesSetting(configPath []string) (files []string) {
+	explanation, err := c.ExplainConfig(configPath)
+	if err != nil {
+		return nil
+	}
+
+	for _, valueSource := range explanation.Sources {
+		if valueSource.Effect != "shadowed" {
+			files = append(files, valueSource.Source.File)
+		}
+	}
+
+	return files
+}
+
+// setOnlyByGlobal returns whether the merged value at `configPath` is only set
+// by the global config of the component or of its ancestors.
+func (c *Component) setOnlyByGlobal(configPath []string) bool {
+	explanation, err := c.ExplainConfig(configPath)
+	if err != nil || len(explanation.Sources) == 0 {
+		return false
+	}
+
+	for _, valueSource := range explanation.Sources {
+		if !valueSource.Source.Global {
+			return false
+		}
+	}
+
+	return true
+}
+
+// schemaFieldPath converts a field reported by the schema validation, eg.
+// `ingress.hosts.0`, into a config path, eg. `ingress`, `hosts`, `[0]`.
+func schemaFieldPath(config interface{}, field string) (configPath []string) {
+	configPath = []string{}
+	if field == "" || field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		return configPath
+	}
+
+	value := config
+	for _, part := range strings.Split(field, ".") {
+		if list, isList := value.([]interface{}); isList {
+			if index, err := strconv.Atoi(part); err == nil && index < len(list) {
+				configPath = append(configPath, fmt.Sprintf("[%d]", index))
+				value = list[index]
+				continue
+			}
+		}
+
+		configPath = append(configPath, part)
+		if configMap, isMap := value.(map[string]interface{}); isMap {
+			value = configMap[part]
+		} else {
+			value = nil
+		}
+	}
+
+	return configPath
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/logger"
+	yaml "github.com/timfpark/yaml"
+)
+
+// EncryptedSuffix marks config files and static manifests encrypted as a
+// whole, eg. `config/prod.enc.yaml`.
+const EncryptedSuffix = ".enc"
+
+// SopsExecutable is the executable decrypting config files and manifests.
+const SopsExecutable = "sops"
+
+// Thread safe store of whether encrypted config files and manifests are
+// decrypted, and with which key.
+type decryptionStore struct {
+	mu         sync.RWMutex
+	enabled    bool
+	ageKeyFile string
+}
+
+// Enable sets whether encrypted config files and manifests are decrypted,
+// with the age key of `ageKeyFile` if not empty.
+func (s *decryptionStore) Enable(enabled bool, ageKeyFile string) {
+	s.mu.Lock()
+	s.enabled = enabled
+	s.ageKeyFile = ageKeyFile
+	s.mu.Unlock()
+}
+
+// Enabled returns whether encrypted config files and manifests are decrypted.
+func (s *decryptionStore) Enabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.enabled
+}
+
+// Decryption is a thread-safe global store of whether Fabrikate decrypts
+// encrypted config files and manifests; only `generate` does.
+var Decryption = decryptionStore{}
+
+// IsEncryptedFile returns whether the file at `filePath` with the content
+// `content` is encrypted with sops: either as a whole, as marked by its
+// name, or with `ENC[...]` values and the `sops` metadata.
+func IsEncryptedFile(filePath string, content []byte) bool {
+	extension := filepath.Ext(filePath)
+	if strings.HasSuffix(strings.TrimSuffix(filePath, extension), EncryptedSuffix) {
+		return true
+	}
+	if !bytes.Contains(content, []byte("ENC[")) {
+		return false
+	}
+
+	metadata := struct {
+		Sops map[string]interface{} `yaml:"sops" json:"sops"`
+	}{}
+	return yaml.Unmarshal(content, &metadata) == nil && len(metadata.Sops) > 0
+}
+
+// Decrypt returns the decrypted content of the file at `filePath`, which is
+// encrypted with sops. Keys are looked up by sops: age keys in the key file
+// passed to Decryption.Enable, $SOPS_AGE_KEY_FILE or $SOPS_AGE_KEY, and PGP
+// keys in the gpg keyring. Nothing is written to disk.
+func (s *decryptionStore) Decrypt(filePath string) ([]byte, error) {
+	s.mu.RLock()
+	ageKeyFile := s.ageKeyFile
+	s.mu.RUnlock()
+
+	// The format of `.enc.yaml` files is that of `.yaml` ones
+	format := strings.TrimPrefix(filepath.Ext(filePath), ".")
+	if format == "yml" {
+		format = "yaml"
+	}
+
+	logger.Info(emoji.Sprintf(":key: Decrypting %s", filePath))
+	cmd := exec.Command(SopsExecutable, "--decrypt", "--input-type", format, "--output-type", format, filePath)
+	cmd.Env = os.Environ()
+	if ageKeyFile != "" {
+		cmd.Env = append(cmd.Env, "SOPS_AGE_KEY_FILE="+ageKeyFile)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("decrypting '%s' with `%s` failed: %w: %s", filePath, SopsExecutable, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
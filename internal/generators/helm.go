@@ -1,18 +1,15 @@
 package generators
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"reflect"
-	"sort"
 	"strings"
-	"sync"
 
-	"github.com/google/uuid"
 	"github.com/kyokomi/emoji"
 	"github.com/microsoft/fabrikate/internal/core"
 	"github.com/microsoft/fabrikate/internal/git"
@@ -24,68 +21,6 @@ import (
 // HelmGenerator provides 'helm generate' generator functionality to Fabrikate
 type HelmGenerator struct{}
 
-type namespaceInjectionResponse struct {
-	index              int
-	namespacedManifest *[]byte
-	err                error
-	warn               *string
-}
-
-// func addNamespaceToManifests(manifests, namespace string) (namespacedManifests string, err error) {
-func addNamespaceToManifests(manifests, namespace string) chan namespaceInjectionResponse {
-	respChan := make(chan namespaceInjectionResponse)
-	syncGroup := sync.WaitGroup{}
-	splitManifest := strings.Split(manifests, "\n---")
-
-	// Wait for all manifests to be iterated over then close the channel
-	syncGroup.Add(len(splitManifest))
-	go func() {
-		syncGroup.Wait()
-		close(respChan)
-	}()
-
-	// Iterate over all manifests, decrementing the wait group for every channel put
-	for index, manifest := range splitManifest {
-		go func(index int, manifest string) {
-			parsedManifest := make(map[interface{}]interface{})
-
-			// Push a warning if unable to unmarshal
-			if err := yaml.Unmarshal([]byte(manifest), &parsedManifest); err != nil {
-				warning := emoji.Sprintf(":question: Unable to unmarshal manifest into type '%s', this is most likely a warning message outputted from `helm template`. Skipping namespace injection of '%s' into manifest: '%s'", reflect.TypeOf(parsedManifest), namespace, manifest)
-				respChan <- namespaceInjectionResponse{warn: &warning}
-				syncGroup.Done()
-				return
-			}
-
-			// strip any empty entries
-			if len(parsedManifest) == 0 {
-				syncGroup.Done()
-				return
-			}
-
-			// Inject the namespace
-			if parsedManifest["metadata"] != nil {
-				metadataMap := parsedManifest["metadata"].(map[interface{}]interface{})
-				if metadataMap["namespace"] == nil {
-					metadataMap["namespace"] = namespace
-				}
-			}
-
-			// Marshal updated manifest and put the response on channel
-			updatedManifest, err := yaml.Marshal(&parsedManifest)
-			if err != nil {
-				respChan <- namespaceInjectionResponse{err: err}
-				syncGroup.Done()
-				return
-			}
-			respChan <- namespaceInjectionResponse{index: index, namespacedManifest: &updatedManifest}
-			syncGroup.Done()
-		}(index, manifest)
-	}
-
-	return respChan
-}
-
 // cleanK8sManifest attempts to remove any invalid entries in k8s yaml.
 // If any entries after being split by "---" are not a map or are empty, they are removed
 func cleanK8sManifest(manifests string) (cleanedManifests string, err error) {
@@ -114,10 +49,10 @@ func cleanK8sManifest(manifests string) (cleanedManifests string, err error) {
 
 // makeHelmRepoPath returns the path where the components helm charts are
 // located -- will be an entire helm repo if `method: git` or just the target
-// chart if `method: helm`
+// chart if `method: helm` or `method: oci`
 func (hg *HelmGenerator) makeHelmRepoPath(c *core.Component) string {
 	// `method: git` will clone the entire helm repo; uses path to point to chart dir
-	if c.Method == "git" || c.Method == "helm" {
+	if c.Method == "git" || c.Method == "helm" || c.Method == "oci" {
 		return path.Join(c.PhysicalPath, "helm_repos", c.Name)
 	}
 
@@ -127,7 +62,7 @@ func (hg *HelmGenerator) makeHelmRepoPath(c *core.Component) string {
 // getChartPath returns the absolute path to the directory containing the
 // Chart.yaml
 func (hg *HelmGenerator) getChartPath(c *core.Component) (string, error) {
-	if c.Method == "helm" || c.Method == "git" {
+	if c.Method == "helm" || c.Method == "git" || c.Method == "oci" {
 		absHelmPath, err := filepath.Abs(hg.makeHelmRepoPath(c))
 		if err != nil {
 			return "", err
@@ -136,8 +71,8 @@ func (hg *HelmGenerator) getChartPath(c *core.Component) (string, error) {
 		case "git":
 			// method: git downloads the entire repo into _helm_chart and the dir containing Chart.yaml specified by Path
 			return path.Join(absHelmPath, c.Path), nil
-		case "helm":
-			// method: helm only downloads target chart into _helm_chart
+		case "helm", "oci":
+			// method: helm/oci only downloads target chart into _helm_chart
 			return absHelmPath, nil
 		}
 	}
@@ -146,6 +81,29 @@ func (hg *HelmGenerator) getChartPath(c *core.Component) (string, error) {
 	return filepath.Abs(path.Join(c.PhysicalPath, c.Path))
 }
 
+// ConfigSchema returns the path of the values.schema.json of the chart of the
+// component, if it ships one, and the default values of the chart, which helm
+// merges the config onto before validating it.
+func (hg *HelmGenerator) ConfigSchema(c *core.Component) (schemaPath string, defaults map[string]interface{}, err error) {
+	chartPath, err := hg.getChartPath(c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	schemaPath = path.Join(chartPath, "values.schema.json")
+	if _, err := os.Stat(schemaPath); err != nil {
+		return "", nil, nil
+	}
+
+	defaults = map[string]interface{}{}
+	valuesPath := path.Join(chartPath, "values.yaml")
+	if err := core.UnmarshalFile(valuesPath, yaml.Unmarshal, &defaults); err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	return schemaPath, defaults, nil
+}
+
 // Generate returns the helm templated manifests specified by this component.
 func (hg *HelmGenerator) Generate(component *core.Component) (manifest string, err error) {
 	logger.Info(emoji.Sprintf(":truck: Generating component '%s' with helm with repo %s", component.Name, component.Source))
@@ -156,18 +114,9 @@ func (hg *HelmGenerator) Generate(component *core.Component) (manifest string, e
 		return "", err
 	}
 
-	// Write helm config to temporary file in tmp folder
-	randomString, err := uuid.NewRandom()
-	if err != nil {
-		return "", err
-	}
-	overriddenValuesFileName := fmt.Sprintf("%s.yaml", randomString.String())
-	absOverriddenPath := path.Join(os.TempDir(), overriddenValuesFileName)
-	defer os.Remove(absOverriddenPath)
-
-	logger.Debug(emoji.Sprintf(":pencil: Writing config %s to %s\n", configYaml, absOverriddenPath))
-	if err = ioutil.WriteFile(absOverriddenPath, configYaml, 0777); err != nil {
-		return "", err
+	// Config decrypted from encrypted config files is kept out of the logs
+	if !component.Config.Encrypted {
+		logger.Debug(emoji.Sprintf(":pencil: Passing config %s to helm\n", configYaml))
 	}
 
 	// Default to `default` namespace unless provided
@@ -176,119 +125,145 @@ func (hg *HelmGenerator) Generate(component *core.Component) (manifest string, e
 		namespace = component.Config.Namespace
 	}
 
-	// Run `helm template` on the chart using the config stored in temp dir
+	// Run `helm template` on the chart with the config piped through stdin, such that it is never written to
+	// disk; it may hold values decrypted from encrypted config files
 	chartPath, err := hg.getChartPath(component)
 	if err != nil {
 		return "", err
 	}
 	logger.Info(emoji.Sprintf(":memo: Running `helm template` on template '%s'", chartPath))
-	output, err := exec.Command("helm", "template", component.Name, chartPath, "--values", absOverriddenPath, "--namespace", namespace).CombinedOutput()
+	templateCmd, err := helm.Command("template", component.Name, chartPath, "--values", "-", "--namespace", namespace)
 	if err != nil {
-		logger.Error(fmt.Sprintf("helm template failed with:\n%s: %s", err, output))
 		return "", err
 	}
-	// Remove any empty/non-map entries in manifests
-	logger.Info(emoji.Sprintf(":scissors: Removing empty entries from generated manifests from chart '%s'", chartPath))
-	stringManifests, err := cleanK8sManifest(string(output))
+	templateCmd.Stdin = bytes.NewReader(configYaml)
+	output, err := templateCmd.CombinedOutput()
 	if err != nil {
+		logger.Error(fmt.Sprintf("helm template failed with:\n%s: %s", err, output))
 		return "", err
 	}
-
+	// Remove any empty/non-map entries in manifests
+	logger.Info(emoji.Sprintf(":scissors: Removing empty entries from generated manifests from chart '%s'", chartPath))
 	// helm template does not inject namespace unless chart directly provides support for it: https://github.com/helm/helm/issues/3553
-	// some helm templates expect Tiller to inject namespace, so enable Fabrikate component designer to
-	// opt into injecting these namespaces manually.  We should reassess if this is necessary after Helm 3 is released and client side
-	// templating really becomes a first class function in Helm.
-	if component.Config.InjectNamespace && component.Config.Namespace != "" {
-		logger.Info(emoji.Sprintf(":syringe: Injecting namespace '%s' into manifests for component '%s'", component.Config.Namespace, component.Name))
-		var successes []namespaceInjectionResponse
-		for resp := range addNamespaceToManifests(stringManifests, component.Config.Namespace) {
-			// If error; return the error immediately
-			if resp.err != nil {
-				logger.Error(emoji.Sprintf(":exclamation: Encountered error while injecting namespace '%s' into manifests for component '%s':\n%s", component.Config.Namespace, component.Name, resp.err))
-				return stringManifests, resp.err
-			}
-
-			// If warning; just log the warning
-			if resp.warn != nil {
-				logger.Warn(emoji.Sprintf(":question: Encountered warning while injecting namespace '%s' into manifests for component '%s':\n%s", component.Config.Namespace, component.Name, *resp.warn))
-			}
+	// components opt into injecting it with `injectNamespace`, which applies to every generator.
+	return cleanK8sManifest(string(output))
+}
 
-			// Add the manifest if one was returned
-			if resp.namespacedManifest != nil {
-				successes = append(successes, resp)
-			}
-		}
+// pullChart pulls the helm chart specified by the passed component into the
+// helm_repos path of the component with `pull`, verifying and recording it
+// against the install lock.
+func (hg *HelmGenerator) pullChart(c *core.Component, helmRepoPath string, pull func(repoURL, chart, version, into string) (string, error)) error {
+	// Pull the locked chart version if present; a digest already pins the chart
+	version := c.Version
+	lockedChart, locked := core.InstallLock.Helm(c.Source, c.Path, c.Version)
+	if locked && !helm.IsDigest(c.Version) {
+		logger.Info(emoji.Sprintf(":lock: Using locked version '%s' of helm chart '%s'", lockedChart.Resolved, c.Path))
+		version = lockedChart.Resolved
+	}
 
-		sort.Slice(successes, func(i, j int) bool {
-			return successes[i].index < successes[j].index
-		})
+	// Pull to a temporary directory
+	tmpHelmDir, err := ioutil.TempDir("", "fabrikate")
+	defer os.RemoveAll(tmpHelmDir)
+	if err != nil {
+		return err
+	}
+	digest, err := pull(c.Source, c.Path, version, tmpHelmDir)
+	if err != nil {
+		return err
+	}
+	if locked && digest != lockedChart.Digest {
+		return fmt.Errorf("digest of helm chart '%s' version '%s' from '%s' is '%s' but %s expects '%s'; run `fab install --update` to accept the new chart", c.Path, version, c.Source, digest, core.LockFilename, lockedChart.Digest)
+	}
 
-		namespacedManifests := ""
-		for _, resp := range successes {
-			namespacedManifests += fmt.Sprintf("---\n%s\n", *resp.namespacedManifest)
-		}
+	// Record what the chart resolved to
+	extractedChartPath := path.Join(tmpHelmDir, path.Base(c.Path))
+	resolvedVersion, err := helm.ChartVersion(extractedChartPath)
+	if err != nil {
+		return err
+	}
+	core.InstallLock.RecordHelm(core.HelmLock{
+		Source:   c.Source,
+		Chart:    c.Path,
+		Version:  c.Version,
+		Resolved: resolvedVersion,
+		Digest:   digest,
+	})
+
+	// Create the component directory -- deleting if it already exists
+	if err := os.RemoveAll(helmRepoPath); err != nil {
+		return err
+	}
 
-		stringManifests = namespacedManifests
+	// ensure the parent directory exists
+	if err := os.MkdirAll(filepath.Dir(helmRepoPath), 0755); err != nil {
+		return err
 	}
 
-	return stringManifests, err
+	// Move the extracted chart from tmp to the helm_repos
+	return os.Rename(extractedChartPath, helmRepoPath)
 }
 
 // Install installs the helm chart specified by the passed component and performs any
 // helm lifecycle events needed.
 func (hg *HelmGenerator) Install(c *core.Component) (err error) {
 	// Install the chart
-	if (c.Method == "helm" || c.Method == "git") && c.Source != "" && c.Path != "" {
+	if (c.Method == "helm" || c.Method == "git" || c.Method == "oci") && c.Source != "" && c.Path != "" {
 		// Download the helm chart
 		helmRepoPath := hg.makeHelmRepoPath(c)
 		switch c.Method {
-		case "helm":
-			logger.Info(emoji.Sprintf(":helicopter: Component '%s' requesting helm chart '%s' from helm repository '%s'", c.Name, c.Path, c.Source))
-			// Pull to a temporary directory
-			tmpHelmDir, err := ioutil.TempDir("", "fabrikate")
-			defer os.RemoveAll(tmpHelmDir)
-			if err != nil {
-				return err
-			}
-			if err = helm.Pull(c.Source, c.Path, c.Version, tmpHelmDir); err != nil {
-				return err
-			}
-
-			// Create the component directory -- deleting if it already exists
-			if err != nil {
-				return err
+		case "helm", "oci":
+			if c.Method == "oci" && !helm.IsOCI(c.Source) {
+				return fmt.Errorf("source of component '%s' with `method: oci` must be an OCI registry starting with 'oci://'; given: '%s'", c.Name, c.Source)
 			}
-			if err := os.RemoveAll(helmRepoPath); err != nil {
-				return err
-			}
-
-			// ensure the parent directory exists
-			if err := os.MkdirAll(filepath.Dir(helmRepoPath), 0755); err != nil {
-				return err
+			logger.Info(emoji.Sprintf(":helicopter: Component '%s' requesting helm chart '%s' from helm repository '%s'", c.Name, c.Path, c.Source))
+			if core.Offline.Enabled() {
+				description := fmt.Sprintf("helm chart '%s' version '%s' from '%s'", c.Path, c.Version, c.Source)
+				return core.InstallOffline(description, helmRepoPath, func() error {
+					return hg.pullChart(c, helmRepoPath, helm.PullCached)
+				})
 			}
 
-			// Move the extracted chart from tmp to the helm_repos
-			extractedChartPath := path.Join(tmpHelmDir, c.Path)
-			if err := os.Rename(extractedChartPath, helmRepoPath); err != nil {
-				return err
-			}
+			return hg.pullChart(c, helmRepoPath, helm.Pull)
 		case "git":
 			// Clone whole repo into helm repo path
 			logger.Info(emoji.Sprintf(":helicopter: Component '%s' requesting helm chart in path '%s' from git repository '%s'", c.Name, c.Source, c.PhysicalPath))
-			cloneOpts := &git.CloneOpts{
+			cloneOpts := git.CloneOpts{
 				URL:    c.Source,
 				SHA:    c.Version,
 				Branch: c.Branch,
 				Into:   helmRepoPath,
 			}
-			if err = git.Clone(cloneOpts); err != nil {
-				return err
-			}
-			// Update chart dependencies in chart path -- this is manually done here but automatically done in downloadChart in the case of `method: helm`
 			chartPath, err := hg.getChartPath(c)
 			if err != nil {
 				return err
 			}
+
+			if core.Offline.Enabled() {
+				// Chart dependencies are only downloaded into the installed
+				// chart, never into the install cache; keep a previous install
+				// which has them rather than replacing it with the cached clone.
+				if downloaded, _ := helm.DependenciesDownloaded(chartPath); downloaded {
+					logger.Info(emoji.Sprintf(":package: Offline: using previously installed helm chart in '%s'", chartPath))
+					return nil
+				}
+
+				description := fmt.Sprintf("helm chart in path '%s' of git repository '%s'", c.Path, c.Source)
+				return core.InstallOffline(description, helmRepoPath, func() error {
+					if err := core.CloneLocked(cloneOpts); err != nil {
+						return err
+					}
+					if downloaded, err := helm.DependenciesDownloaded(chartPath); err != nil || downloaded {
+						return err
+					}
+					core.Offline.RecordMissing(fmt.Sprintf("dependencies of %s", description))
+					return nil
+				})
+			}
+
+			if err = core.CloneLocked(cloneOpts); err != nil {
+				return err
+			}
+			// Update chart dependencies in chart path -- this is manually done here but automatically done in downloadChart in the case of `method: helm`
 			if err = helm.DependencyUpdate(chartPath); err != nil {
 				return err
 			}
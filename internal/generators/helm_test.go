@@ -1,9 +1,17 @@
 package generators
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/microsoft/fabrikate/internal/helm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,3 +39,162 @@ should: not be removed
 	entries := strings.Split(cleaned, "\n---")
 	assert.Equal(t, 2, len(entries))
 }
+
+func TestHelmConfigSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate-helm-schema")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	chartPath := path.Join(dir, "chart")
+	assert.Nil(t, os.MkdirAll(chartPath, 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(chartPath, "values.yaml"), []byte("image: nginx\nreplicas: 1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(path.Join(chartPath, "values.schema.json"), []byte(`{
+  "type": "object",
+  "properties": {
+    "image": { "type": "string" },
+    "replicas": { "type": "integer" }
+  },
+  "required": ["image"]
+}`), 0644))
+
+	component := core.Component{
+		Name:          "chart",
+		ComponentType: "helm",
+		PhysicalPath:  dir,
+		LogicalPath:   "./",
+		Path:          "chart",
+		Config:        core.NewComponentConfig(dir),
+	}
+	generator := &HelmGenerator{}
+
+	schemaPath, defaults, err := generator.ConfigSchema(&component)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(chartPath, "values.schema.json"), schemaPath)
+	assert.Equal(t, "nginx", defaults["image"])
+
+	// required values are provided by the chart defaults
+	component.Config.Config["replicas"] = 3
+	assert.Nil(t, component.ValidateConfig(generator))
+
+	component.Config.Config["replicas"] = "3"
+	assert.NotNil(t, component.ValidateConfig(generator))
+
+	// charts without a values.schema.json are not validated
+	assert.Nil(t, os.Remove(schemaPath))
+	assert.Nil(t, component.ValidateConfig(generator))
+}
+
+// fakeHelm puts a `helm` running the shell script `script` in `dir` first in
+// the PATH; returns the function restoring it.
+func fakeHelm(t *testing.T, dir string, script string) func() {
+	binPath := path.Join(dir, "bin")
+	assert.Nil(t, os.MkdirAll(binPath, 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(binPath, "helm"), []byte("#!/bin/sh\n"+script), 0755))
+
+	originalPath := os.Getenv("PATH")
+	os.Setenv("PATH", binPath+string(os.PathListSeparator)+originalPath)
+	return func() {
+		os.Setenv("PATH", originalPath)
+		helm.Cleanup()
+	}
+}
+
+func TestHelmGenerateValuesFromStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helm is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "fabrikate-helm-stdin")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// A fake helm recording its arguments and stdin
+	defer fakeHelm(t, dir, fmt.Sprintf(`echo "$@" > %s/args
+cat > %s/values
+printf 'apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n'
+`, dir, dir))()
+
+	component := core.Component{
+		Name:          "db",
+		ComponentType: "helm",
+		PhysicalPath:  dir,
+		Path:          "chart",
+		Config:        core.NewComponentConfig(dir),
+	}
+	component.Config.Config["password"] = "decrypted"
+	component.Config.Encrypted = true
+
+	generator := &HelmGenerator{}
+	manifest, err := generator.Generate(&component)
+	assert.Nil(t, err)
+	assert.Contains(t, manifest, "kind: Secret")
+
+	// The values are piped to helm, never written to a file
+	args, err := ioutil.ReadFile(path.Join(dir, "args"))
+	assert.Nil(t, err)
+	assert.Equal(t, "template db "+path.Join(dir, "chart")+" --values - --namespace default\n", string(args))
+	values, err := ioutil.ReadFile(path.Join(dir, "values"))
+	assert.Nil(t, err)
+	assert.Equal(t, "password: decrypted\n", string(values))
+}
+
+func TestHelmInstallOCIDigestOffline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helm is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache.SetDir(path.Join(dir, "cache"))
+	defer cache.SetDir("")
+	core.InstallLock.Reset(core.LockFile{}, false)
+	defer core.InstallLock.Reset(core.LockFile{}, false)
+
+	// A fake helm pulling a chart archive, and recording the pulls
+	chartPath := path.Join(dir, "charts", "podinfo")
+	assert.Nil(t, os.MkdirAll(chartPath, 0755))
+	assert.Nil(t, ioutil.WriteFile(path.Join(chartPath, "Chart.yaml"), []byte("apiVersion: v2\nname: podinfo\nversion: 6.0.0\n"), 0644))
+	defer fakeHelm(t, dir, fmt.Sprintf(`echo "$2" >> %s/pulls
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--destination" ]; then destination="$2"; fi
+  shift
+done
+tar czf "$destination/podinfo-6.0.0.tgz" -C %s/charts podinfo
+`, dir, dir))()
+
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	component := core.Component{
+		Name:          "podinfo",
+		ComponentType: "helm",
+		Method:        "oci",
+		Source:        "oci://registry.example.com/charts",
+		Path:          "podinfo",
+		Version:       digest,
+		PhysicalPath:  dir,
+	}
+	installedChart := path.Join(dir, "helm_repos", "podinfo", "Chart.yaml")
+
+	generator := &HelmGenerator{}
+	assert.Nil(t, generator.Install(&component))
+	assert.FileExists(t, installedChart)
+
+	// The chart is cached under its digest
+	entries, err := cache.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, strings.TrimPrefix(digest, "sha256:"), entries[0].Key)
+
+	// such that it is installed offline, from the cache rather than a previous install
+	assert.Nil(t, os.RemoveAll(path.Join(dir, "helm_repos")))
+	core.Offline.Enable(true)
+	defer core.Offline.Enable(false)
+	defer core.Offline.Reset()
+	assert.Nil(t, generator.Install(&component))
+	assert.FileExists(t, installedChart)
+	assert.Nil(t, core.Offline.Err())
+
+	pulls, err := ioutil.ReadFile(path.Join(dir, "pulls"))
+	assert.Nil(t, err)
+	assert.Equal(t, "oci://registry.example.com/charts/podinfo@"+digest+"\n", string(pulls))
+}
@@ -0,0 +1,108 @@
+package generators
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/microsoft/fabrikate/internal/git"
+	"github.com/microsoft/fabrikate/internal/logger"
+)
+
+// KustomizeGenerator provides 'kustomize build' generator functionality to Fabrikate
+type KustomizeGenerator struct{}
+
+// makeKustomizeRepoPath returns the path where the components kustomize
+// sources are located -- will be an entire git repo if `method: git`
+func (kg *KustomizeGenerator) makeKustomizeRepoPath(c *core.Component) string {
+	// `method: git` will clone the entire repo; uses path to point to kustomization dir
+	if c.Method == "git" {
+		return path.Join(c.PhysicalPath, "components", c.Name)
+	}
+
+	return c.PhysicalPath
+}
+
+// getKustomizationPath returns the absolute path to the directory containing
+// the kustomization.yaml
+func (kg *KustomizeGenerator) getKustomizationPath(c *core.Component) (string, error) {
+	return filepath.Abs(path.Join(kg.makeKustomizeRepoPath(c), c.Path))
+}
+
+// kustomizeBuildCommand returns the command building the kustomization at
+// `kustomizationPath`; `kustomize build` if kustomize is installed, otherwise
+// the kustomize built into kubectl.
+func kustomizeBuildCommand(kustomizationPath string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath("kustomize"); err == nil {
+		return exec.Command("kustomize", "build", kustomizationPath), nil
+	}
+
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return nil, fmt.Errorf("neither `kustomize` nor `kubectl` were found in PATH; one is required to generate kustomize components")
+	}
+
+	return exec.Command("kubectl", "kustomize", kustomizationPath), nil
+}
+
+// Generate returns the manifests built by kustomize from the kustomization
+// specified by this component.
+func (kg *KustomizeGenerator) Generate(component *core.Component) (manifest string, err error) {
+	logger.Info(emoji.Sprintf(":truck: Generating component '%s' with kustomize from path %s", component.Name, component.Path))
+
+	kustomizationPath, err := kg.getKustomizationPath(component)
+	if err != nil {
+		return "", err
+	}
+
+	buildCmd, err := kustomizeBuildCommand(kustomizationPath)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info(emoji.Sprintf(":memo: Running `%s` on kustomization '%s'", buildCmd.Args[0], kustomizationPath))
+	var stdout, stderr bytes.Buffer
+	buildCmd.Stdout = &stdout
+	buildCmd.Stderr = &stderr
+	if err := buildCmd.Run(); err != nil {
+		logger.Error(fmt.Sprintf("kustomize build of '%s' failed with:\n%s: %s", kustomizationPath, err, stderr.String()))
+		return "", err
+	}
+
+	return cleanK8sManifest(stdout.String())
+}
+
+// Install clones the git repository containing the kustomization specified by
+// the passed component; This is a noop for all other methods.
+func (kg *KustomizeGenerator) Install(c *core.Component) (err error) {
+	if c.Method != "git" || c.Source == "" {
+		return nil
+	}
+
+	logger.Info(emoji.Sprintf(":helicopter: Component '%s' requesting kustomization in path '%s' from git repository '%s'", c.Name, c.Path, c.Source))
+	kustomizeRepoPath := kg.makeKustomizeRepoPath(c)
+	cloneOpts := git.CloneOpts{
+		URL:    c.Source,
+		SHA:    c.Version,
+		Branch: c.Branch,
+		Into:   kustomizeRepoPath,
+	}
+
+	if core.Offline.Enabled() {
+		description := fmt.Sprintf("kustomization in path '%s' of git repository '%s'", c.Path, c.Source)
+		return core.InstallOffline(description, kustomizeRepoPath, func() error {
+			return core.CloneLocked(cloneOpts)
+		})
+	}
+
+	// ensure the parent directory exists
+	if err := os.MkdirAll(filepath.Dir(kustomizeRepoPath), 0755); err != nil {
+		return err
+	}
+
+	return core.CloneLocked(cloneOpts)
+}
@@ -0,0 +1,37 @@
+package generators
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetKustomizationPath(t *testing.T) {
+	generator := &KustomizeGenerator{}
+
+	gitComponent := core.Component{
+		Name:          "podinfo",
+		ComponentType: "kustomize",
+		Method:        "git",
+		Source:        "https://github.com/stefanprodan/podinfo",
+		Path:          "kustomize",
+		PhysicalPath:  "infra",
+	}
+	kustomizationPath, err := generator.getKustomizationPath(&gitComponent)
+	assert.Nil(t, err)
+	expectedPath, _ := filepath.Abs("infra/components/podinfo/kustomize")
+	assert.Equal(t, expectedPath, kustomizationPath)
+
+	localComponent := core.Component{
+		Name:          "overlay",
+		ComponentType: "kustomize",
+		Path:          "./overlays/prod",
+		PhysicalPath:  "infra",
+	}
+	kustomizationPath, err = generator.getKustomizationPath(&localComponent)
+	assert.Nil(t, err)
+	expectedPath, _ = filepath.Abs("infra/overlays/prod")
+	assert.Equal(t, expectedPath, kustomizationPath)
+}
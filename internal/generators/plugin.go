@@ -0,0 +1,133 @@
+package generators
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/microsoft/fabrikate/internal/logger"
+)
+
+// PluginProtocolVersion is the version of the request external generators are
+// passed on stdin.
+const PluginProtocolVersion = "v1"
+
+// PluginRequest documentation: https://github.com/microsoft/fabrikate/blob/master/docs/generators.md
+//
+// PluginRequest is the JSON document passed on stdin to external generators.
+type PluginRequest struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Component       core.Component         `json:"component"`
+	Config          map[string]interface{} `json:"config"`
+	Namespace       string                 `json:"namespace,omitempty"`
+	PhysicalPath    string                 `json:"physicalPath"`
+	LogicalPath     string                 `json:"logicalPath"`
+	Offline         bool                   `json:"offline,omitempty"`
+}
+
+// PluginGenerator runs an external executable implementing the generator
+// plugin protocol for components of a type not built into Fabrikate.
+type PluginGenerator struct {
+	ComponentType string
+	Executable    string
+}
+
+// run runs the plugin executable with `verb`, passing the request for
+// `component` on stdin, and returns what it wrote to stdout.
+func (pg *PluginGenerator) run(verb string, component *core.Component) (stdout string, err error) {
+	absPhysicalPath, err := filepath.Abs(component.PhysicalPath)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := json.Marshal(PluginRequest{
+		ProtocolVersion: PluginProtocolVersion,
+		Component:       *component,
+		Config:          jsonCompatibleMap(component.Config.Config),
+		Namespace:       component.Config.Namespace,
+		PhysicalPath:    absPhysicalPath,
+		LogicalPath:     component.LogicalPath,
+		Offline:         core.Offline.Enabled(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info(emoji.Sprintf(":electric_plug: Running `%s %s` for component '%s'", pg.Executable, verb, component.Name))
+	cmd := exec.Command(pg.Executable, verb)
+	cmd.Dir = absPhysicalPath
+	cmd.Stdin = bytes.NewReader(request)
+	var stdoutBuffer, stderr bytes.Buffer
+	cmd.Stdout = &stdoutBuffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("generator `%s %s` for component '%s' of type '%s' failed: %w: %s", pg.Executable, verb, component.Name, pg.ComponentType, err, strings.TrimSpace(stderr.String()))
+	}
+	if stderr.Len() > 0 {
+		logger.Debug(fmt.Sprintf("generator `%s %s` for component '%s' wrote to stderr:\n%s", pg.Executable, verb, component.Name, stderr.String()))
+	}
+
+	return stdoutBuffer.String(), nil
+}
+
+// Generate returns the manifests the plugin writes to stdout when run with the
+// `generate` verb.
+func (pg *PluginGenerator) Generate(component *core.Component) (manifest string, err error) {
+	logger.Info(emoji.Sprintf(":truck: Generating component '%s' with external generator '%s'", component.Name, pg.Executable))
+
+	output, err := pg.run("generate", component)
+	if err != nil {
+		return "", err
+	}
+
+	return cleanK8sManifest(output)
+}
+
+// Install runs the plugin with the `install` verb.
+func (pg *PluginGenerator) Install(component *core.Component) (err error) {
+	output, err := pg.run("install", component)
+	if len(output) > 0 {
+		logger.Debug(fmt.Sprintf("generator `%s install` for component '%s' wrote to stdout:\n%s", pg.Executable, component.Name, output))
+	}
+
+	return err
+}
+
+// jsonCompatibleMap returns a copy of `m` in which every nested
+// map[interface{}]interface{} (as decoded from YAML) is converted to a
+// map[string]interface{} such that it can be serialized to JSON.
+func jsonCompatibleMap(m map[string]interface{}) map[string]interface{} {
+	converted := map[string]interface{}{}
+	for key, value := range m {
+		converted[key] = jsonCompatible(value)
+	}
+
+	return converted
+}
+
+func jsonCompatible(value interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return jsonCompatibleMap(typedValue)
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, nestedValue := range typedValue {
+			converted[fmt.Sprintf("%v", key)] = jsonCompatible(nestedValue)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(typedValue))
+		for index, nestedValue := range typedValue {
+			converted[index] = jsonCompatible(nestedValue)
+		}
+		return converted
+	}
+
+	return value
+}
@@ -0,0 +1,71 @@
+package generators
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	generator, err := Get("component")
+	assert.Nil(t, err)
+	assert.Nil(t, generator)
+
+	generator, err = Get("helm")
+	assert.Nil(t, err)
+	assert.IsType(t, &HelmGenerator{}, generator)
+
+	_, err = Get("does-not-exist")
+	assert.NotNil(t, err)
+}
+
+func TestPluginGenerator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// A plugin which records its request and generates a ConfigMap named after the verb
+	plugin := "#!/bin/sh\ncat > request.json\necho 'apiVersion: v1'\necho 'kind: ConfigMap'\necho 'metadata:'\necho \"  name: $1\"\n"
+	assert.Nil(t, ioutil.WriteFile(path.Join(dir, PluginPrefix+"test"), []byte(plugin), 0755))
+
+	originalPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", originalPath)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+originalPath)
+
+	generator, err := Get("test")
+	assert.Nil(t, err)
+	assert.IsType(t, &PluginGenerator{}, generator)
+
+	component := core.Component{
+		Name:          "foo",
+		ComponentType: "test",
+		PhysicalPath:  dir,
+		LogicalPath:   "foo",
+	}
+	component.Config.Namespace = "foo"
+	component.Config.InjectNamespace = true
+	component.Config.Config = map[string]interface{}{
+		"nested": map[interface{}]interface{}{"replicas": 2},
+	}
+
+	// The namespace is injected into the manifest of plugins as into those of any generator
+	assert.Nil(t, component.Generate(generator))
+	assert.Contains(t, component.Manifest, "name: generate")
+	assert.Contains(t, component.Manifest, "namespace: foo")
+
+	requestJSON, err := ioutil.ReadFile(path.Join(dir, "request.json"))
+	assert.Nil(t, err)
+	request := PluginRequest{}
+	assert.Nil(t, json.Unmarshal(requestJSON, &request))
+	assert.Equal(t, PluginProtocolVersion, request.ProtocolVersion)
+	assert.Equal(t, "foo", request.Component.Name)
+	assert.Equal(t, "foo", request.Namespace)
+	assert.Equal(t, float64(2), request.Config["nested"].(map[string]interface{})["replicas"])
+
+	assert.Nil(t, generator.Install(&component))
+}
@@ -0,0 +1,66 @@
+package generators
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+
+	"github.com/microsoft/fabrikate/internal/core"
+	"github.com/spf13/viper"
+)
+
+// PluginPrefix is the prefix of the executables implementing external
+// generators; `type: foo` resolves to `fab-generator-foo` on PATH.
+const PluginPrefix = "fab-generator-"
+
+// Thread safe store of the generators built into Fabrikate by component type
+type generatorRegistry struct {
+	mu         sync.RWMutex
+	generators map[string]func() core.Generator
+}
+
+var registry = generatorRegistry{
+	generators: map[string]func() core.Generator{
+		"helm":      func() core.Generator { return &HelmGenerator{} },
+		"static":    func() core.Generator { return &StaticGenerator{} },
+		"kustomize": func() core.Generator { return &KustomizeGenerator{} },
+	},
+}
+
+// Register registers `newGenerator` as the constructor of the generator for
+// components of type `componentType`, replacing any existing one.
+func Register(componentType string, newGenerator func() core.Generator) {
+	registry.mu.Lock()
+	registry.generators[componentType] = newGenerator
+	registry.mu.Unlock()
+}
+
+// Get returns the generator for components of type `componentType`: a
+// registered generator if any, otherwise an external generator plugin.
+// Components of type `component` have no generator; nil is returned.
+//
+// External generators are looked up by the `generators.<componentType>` key in
+// ~/.fab.yaml, falling back to the `fab-generator-<componentType>` executable
+// on PATH.
+func Get(componentType string) (core.Generator, error) {
+	if componentType == "component" || componentType == "" {
+		return nil, nil
+	}
+
+	registry.mu.RLock()
+	newGenerator, registered := registry.generators[componentType]
+	registry.mu.RUnlock()
+	if registered {
+		return newGenerator(), nil
+	}
+
+	executable := viper.GetString("generators." + componentType)
+	if executable == "" {
+		var err error
+		if executable, err = exec.LookPath(PluginPrefix + componentType); err != nil {
+			return nil, fmt.Errorf("no generator found for component type '%s'; install `%s%s` in PATH or set `generators.%s` in ~/.fab.yaml to the path of its generator", componentType, PluginPrefix, componentType, componentType)
+		}
+	}
+
+	return &PluginGenerator{ComponentType: componentType, Executable: executable}, nil
+}
@@ -1,6 +1,7 @@
 package generators
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/cache"
 	"github.com/microsoft/fabrikate/internal/core"
 	"github.com/microsoft/fabrikate/internal/logger"
 )
@@ -49,6 +51,17 @@ func (sg *StaticGenerator) Generate(component *core.Component) (manifest string,
 			return "", err
 		}
 
+		if core.IsEncryptedFile(staticFilePath, staticFileManifest) {
+			if !core.Decryption.Enabled() {
+				logger.Debug(emoji.Sprintf(":lock: Skipping encrypted manifest %s of component '%s'; only generate decrypts manifests", staticFilePath, component.Name))
+				component.SkippedManifests = append(component.SkippedManifests, staticFilePath)
+				continue
+			}
+			if staticFileManifest, err = core.Decryption.Decrypt(staticFilePath); err != nil {
+				return "", err
+			}
+		}
+
 		manifests += fmt.Sprintf("---\n%s\n", staticFileManifest)
 	}
 
@@ -74,30 +87,99 @@ func (sg *StaticGenerator) Install(c *core.Component) (err error) {
 			return fmt.Errorf("source for 'static' component '%s' must end in one of %v; given: '%s'", c.Name, validSourceExtensions, c.Source)
 		}
 
+		componentsPath := path.Join(c.PhysicalPath, "components", c.Name)
+		if core.Offline.Enabled() {
+			description := fmt.Sprintf("manifest '%s'", c.Source)
+			return core.InstallOffline(description, componentsPath, func() error {
+				// Only the locked manifest is known to be in the install cache
+				lockedManifest, locked := core.InstallLock.HTTP(c.Source)
+				if !locked {
+					return fmt.Errorf("%w: manifest '%s' is not in %s", cache.ErrNotCached, c.Source, core.LockFilename)
+				}
+				cachedPath, err := cache.HTTPPath(c.Source, lockedManifest.Digest)
+				if err != nil {
+					return err
+				}
+				if !cache.Lookup(cachedPath) {
+					return fmt.Errorf("%w: manifest '%s'", cache.ErrNotCached, c.Source)
+				}
+
+				logger.Info(emoji.Sprintf(":atm: Found manifest '%s' in install cache '%s'; skipping download", c.Source, cachedPath))
+				core.InstallLock.RecordHTTP(lockedManifest)
+				return sg.installManifest(c, cachedPath)
+			})
+		}
+
 		response, err := http.Get(c.Source)
 		if err != nil {
 			return err
 		}
 		defer response.Body.Close()
 
-		componentsPath := path.Join(c.PhysicalPath, "components", c.Name)
-		if err := os.MkdirAll(componentsPath, 0777); err != nil {
+		// Error pages must never be locked nor cached as the manifest
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("error downloading manifest '%s' for component '%s': %s", c.Source, c.Name, response.Status)
+		}
+
+		// Download the resource manifest into the install cache, hashing it as it is written
+		downloadPath, err := cache.TempDir()
+		if err != nil {
 			return err
 		}
+		defer os.RemoveAll(downloadPath)
 
-		// Write the downloaded resource manifest file
-		out, err := os.Create(path.Join(componentsPath, c.Name+".yaml"))
+		out, err := os.Create(path.Join(downloadPath, cache.HTTPManifestFilename))
 		if err != nil {
 			logger.Error(emoji.Sprintf(":no_entry_sign: Error occurred in install for component '%s'\nError: %s", c.Name, err))
 			return err
 		}
 		defer out.Close()
 
-		if _, err = io.Copy(out, response.Body); err != nil {
+		hash := sha256.New()
+		if _, err = io.Copy(io.MultiWriter(out, hash), response.Body); err != nil {
 			logger.Error(emoji.Sprintf(":no_entry_sign: Error occurred in writing manifest file for component '%s'\nError: %s", c.Name, err))
 			return err
 		}
+		if err = out.Close(); err != nil {
+			return err
+		}
+
+		// Ensure the downloaded manifest matches the lock file
+		digest := fmt.Sprintf("sha256:%x", hash.Sum(nil))
+		if lockedManifest, locked := core.InstallLock.HTTP(c.Source); locked && lockedManifest.Digest != digest {
+			return fmt.Errorf("digest of '%s' for component '%s' is '%s' but %s expects '%s'; run `fab install --update` to accept the new manifest", c.Source, c.Name, digest, core.LockFilename, lockedManifest.Digest)
+		}
+		core.InstallLock.RecordHTTP(core.HTTPLock{
+			Source: c.Source,
+			Digest: digest,
+		})
+
+		cachedPath, err := cache.HTTPPath(c.Source, digest)
+		if err != nil {
+			return err
+		}
+		if err = cache.Store(downloadPath, cachedPath); err != nil {
+			return err
+		}
+
+		return sg.installManifest(c, cachedPath)
 	}
 
 	return nil
 }
+
+// installManifest copies the manifest in the http install cache entry at
+// `cachedPath` to the components path of `c`.
+func (sg *StaticGenerator) installManifest(c *core.Component, cachedPath string) error {
+	componentsPath := path.Join(c.PhysicalPath, "components", c.Name)
+	if err := os.MkdirAll(componentsPath, 0777); err != nil {
+		return err
+	}
+
+	manifest, err := ioutil.ReadFile(path.Join(cachedPath, cache.HTTPManifestFilename))
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(componentsPath, c.Name+".yaml"), manifest, 0644)
+}
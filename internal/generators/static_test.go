@@ -1,8 +1,14 @@
 package generators
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
 	"testing"
 
+	"github.com/microsoft/fabrikate/internal/cache"
 	"github.com/microsoft/fabrikate/internal/core"
 	"github.com/stretchr/testify/assert"
 )
@@ -32,3 +38,35 @@ func TestGetStaticComponentPath(t *testing.T) {
 
 	assert.Equal(t, expectedComponentPath, componentPath)
 }
+
+func TestStaticGenerator_InstallFailedDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache.SetDir(path.Join(dir, "cache"))
+	defer cache.SetDir("")
+	core.InstallLock.Reset(core.LockFile{}, false)
+	defer core.InstallLock.Reset(core.LockFile{}, false)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	component := core.Component{
+		Name:          "manifest",
+		ComponentType: "static",
+		Method:        "http",
+		Source:        server.URL + "/manifest.yaml",
+		PhysicalPath:  dir,
+	}
+	generator := &StaticGenerator{}
+	err = generator.Install(&component)
+	assert.EqualError(t, err, "error downloading manifest '"+component.Source+"' for component 'manifest': 404 Not Found")
+
+	// The error page is neither locked, cached, nor installed
+	assert.Equal(t, 0, len(core.InstallLock.Resolved().HTTP))
+	entries, err := cache.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+	_, err = os.Stat(path.Join(dir, "components", "manifest"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 
-	"github.com/google/uuid"
 	"github.com/kyokomi/emoji"
+	installcache "github.com/microsoft/fabrikate/internal/cache"
 	"github.com/microsoft/fabrikate/internal/logger"
 	"github.com/otiai10/copy"
 )
@@ -47,7 +47,8 @@ func (cache *gitCache) set(cacheToken string, cloneResult *gitCloneResult) {
 
 // A future like struct to hold the result of git clone
 type gitCloneResult struct {
-	ClonePath string // The abs path in os.TempDir() where the the item was cloned to
+	ClonePath string // The abs path in the install cache where the the item was cloned to
+	Commit    string // The commit SHA the clone resolved to
 	Error     error  // An error which occurred during the clone
 	mu        sync.RWMutex
 }
@@ -69,9 +70,15 @@ func cacheKey(repo, branch, commit string) string {
 	return fmt.Sprintf("%v@%v:%v", repo, branch, commit)
 }
 
-// cloneRepo clones a target git repository into the hosts temporary directory
-// and returns a gitCloneResult pointing to that location on filesystem
-func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan *gitCloneResult {
+// fullCommit matches a full (non-abbreviated) git commit SHA
+var fullCommit = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// cloneRepo clones a target git repository into the install cache and returns
+// a gitCloneResult pointing to that location on filesystem. If the repository
+// was previously cloned at the requested commit, the cached clone is reused.
+// When `offline`, only the install cache is used and the result errors with
+// installcache.ErrNotCached if the commit is not in it.
+func (cache *gitCache) cloneRepo(repo string, commit string, branch string, offline bool) chan *gitCloneResult {
 	cloneResultChan := make(chan *gitCloneResult)
 
 	go func() {
@@ -94,16 +101,44 @@ func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan
 		}()
 		cache.set(cacheToken, &cloneResult) // store future in cache
 
+		// Record the error on the future so later requests for the same clone see it too
+		fail := func(err error) {
+			cloneResult.Error = err
+			cloneResultChan <- &cloneResult
+		}
+
+		// Reuse the install cache if the exact commit was cloned by a previous run
+		if fullCommit.MatchString(commit) {
+			cachedPath, err := installcache.GitPath(repo, commit)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if installcache.Lookup(cachedPath) {
+				logger.Info(emoji.Sprintf(":atm: Found '%s' in install cache '%s'; skipping clone", cacheToken, cachedPath))
+				cloneResult.ClonePath = cachedPath
+				cloneResult.Commit = commit
+				cloneResultChan <- &cloneResult
+				return
+			}
+		}
+
+		if offline {
+			fail(fmt.Errorf("%w: repository '%s' at commit '%s'", installcache.ErrNotCached, repo, commit))
+			return
+		}
+
 		// Default options for a clone
 		cloneCommandArgs := []string{"clone"}
 
 		// check for access token and append to repo if present
+		cloneURL := repo
 		if token, exists := AccessTokens.Get(repo); exists {
 			// Only match when the repo string does not contain a an access token already
 			// "(https?)://(?!(.+:)?.+@)(.+)" would be preferred but go does not support negative lookahead
 			pattern, err := regexp.Compile("^(https?)://([^@]+@)?(.+)$")
 			if err != nil {
-				cloneResultChan <- &gitCloneResult{Error: err}
+				fail(err)
 				return
 			}
 			// If match is found, inject the access token into the repo string
@@ -111,12 +146,12 @@ func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan
 				protocol := matches[1]
 				// credentialsWithAtSign := matches[2]
 				cleanedRepoString := matches[3]
-				repo = fmt.Sprintf("%v://%v@%v", protocol, token, cleanedRepoString)
+				cloneURL = fmt.Sprintf("%v://%v@%v", protocol, token, cleanedRepoString)
 			}
 		}
 
 		// Add repo to clone args
-		cloneCommandArgs = append(cloneCommandArgs, repo)
+		cloneCommandArgs = append(cloneCommandArgs, cloneURL)
 
 		// Only fetch latest commit if commit not provided
 		if len(commit) == 0 {
@@ -132,13 +167,12 @@ func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan
 			cloneCommandArgs = append(cloneCommandArgs, "--branch", branch)
 		}
 
-		// Clone into a random path in the host temp dir
-		randomFolderName, err := uuid.NewRandom()
+		// Clone into a temporary directory in the install cache
+		clonePathOnFS, err := installcache.TempDir()
 		if err != nil {
-			cloneResultChan <- &gitCloneResult{Error: err}
+			fail(err)
 			return
 		}
-		clonePathOnFS := path.Join(os.TempDir(), randomFolderName.String())
 		logger.Info(emoji.Sprintf(":helicopter: Cloning %s => %s", cacheToken, clonePathOnFS))
 		cloneCommandArgs = append(cloneCommandArgs, clonePathOnFS)
 		cloneCommand := exec.Command("git", cloneCommandArgs...)
@@ -150,7 +184,8 @@ func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan
 		cloneCommand.Stderr = &stderr
 		if err := cloneCommand.Run(); err != nil {
 			logger.Error(emoji.Sprintf(":no_entry_sign: Error occurred while cloning: '%s'\n%s: %s", cacheToken, err, stderr.String()))
-			cloneResultChan <- &gitCloneResult{Error: fmt.Errorf("%v: %v", err, stderr.String())}
+			_ = os.RemoveAll(clonePathOnFS)
+			fail(fmt.Errorf("%v: %v", err, stderr.String()))
 			return
 		}
 
@@ -164,13 +199,39 @@ func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan
 			checkoutCommit.Dir = clonePathOnFS
 			if err := checkoutCommit.Run(); err != nil {
 				logger.Error(emoji.Sprintf(":no_entry_sign: Error occurred checking out commit '%s' from repo '%s'\n%s: %s", commit, repo, err, stderr.String()))
-				cloneResultChan <- &gitCloneResult{Error: fmt.Errorf("%v: %v", err, stderr.String())}
+				_ = os.RemoveAll(clonePathOnFS)
+				fail(fmt.Errorf("%v: %v", err, stderr.String()))
 				return
 			}
 		}
 
+		// Resolve the commit the clone ended up on so it can be locked and cached
+		revParse := exec.Command("git", "rev-parse", "HEAD")
+		var revParseStderr bytes.Buffer
+		revParse.Stderr = &revParseStderr
+		revParse.Dir = clonePathOnFS
+		resolvedCommit, err := revParse.Output()
+		if err != nil {
+			logger.Error(emoji.Sprintf(":no_entry_sign: Error occurred resolving commit of repo '%s'\n%s: %s", repo, err, revParseStderr.String()))
+			_ = os.RemoveAll(clonePathOnFS)
+			fail(fmt.Errorf("%v: %v", err, revParseStderr.String()))
+			return
+		}
+		cloneResult.Commit = strings.TrimSpace(string(resolvedCommit))
+
+		// Move the clone into its place in the install cache
+		cachedPath, err := installcache.GitPath(repo, cloneResult.Commit)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if err := installcache.Store(clonePathOnFS, cachedPath); err != nil {
+			fail(err)
+			return
+		}
+
 		// Save the gitCloneResult into cache
-		cloneResult.ClonePath = clonePathOnFS
+		cloneResult.ClonePath = cachedPath
 
 		// Push the cached result to the channel
 		cloneResultChan <- &cloneResult
@@ -181,55 +242,39 @@ func (cache *gitCache) cloneRepo(repo string, commit string, branch string) chan
 
 // CloneOpts are the options you can pass to Clone
 type CloneOpts struct {
-	URL    string
-	SHA    string
-	Branch string
-	Into   string
+	URL     string
+	SHA     string
+	Branch  string
+	Into    string
+	Offline bool // Only copy from the install cache; never clone
 }
 
 // Clone is a helper func to centralize cloning a repository with the spec
 // provided by its arguments.
-func Clone(opts *CloneOpts) (err error) {
+//
+// Returns the commit SHA the clone resolved to.
+func Clone(opts *CloneOpts) (commit string, err error) {
 	// Clone and get the location of where it was cloned to in tmp
-	result := <-cache.cloneRepo(opts.URL, opts.SHA, opts.Branch)
+	result := <-cache.cloneRepo(opts.URL, opts.SHA, opts.Branch, opts.Offline)
 	clonePath := result.get()
 	if result.Error != nil {
-		return result.Error
+		return "", result.Error
 	}
 
 	// Remove the into directory if it already exists
 	if err = os.RemoveAll(opts.Into); err != nil {
-		return err
+		return "", err
 	}
 
 	// copy the repo from tmp cache to component path
 	absIntoPath, err := filepath.Abs(opts.Into)
 	if err != nil {
-		return err
+		return "", err
 	}
 	logger.Info(emoji.Sprintf(":truck: Copying %s => %s", clonePath, absIntoPath))
 	if err = copy.Copy(clonePath, opts.Into); err != nil {
-		return err
+		return "", err
 	}
 
-	return err
-}
-
-// ClearCache deletes all temporary folders created as temporary cache for
-// git clones.
-func ClearCache() (err error) {
-	logger.Info(emoji.Sprintf(":bomb: Cleaning up git cache..."))
-	cache.mu.Lock()
-	for key, value := range cache.cache {
-		logger.Info(emoji.Sprintf(":bomb: Removing git cache directory '%s'", value.ClonePath))
-		if err = os.RemoveAll(value.ClonePath); err != nil {
-			logger.Error(emoji.Sprintf(":exclamation: Error deleting temporary directory '%s'", value.ClonePath))
-			cache.mu.Unlock()
-			return err
-		}
-		delete(cache.cache, key)
-	}
-	cache.mu.Unlock()
-	logger.Info(emoji.Sprintf(":white_check_mark: Completed cache clean!"))
-	return err
+	return result.Commit, err
 }
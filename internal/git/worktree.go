@@ -0,0 +1,71 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/logger"
+)
+
+// Worktree checks out `ref` of the git repository containing `path` into a
+// temporary worktree.
+//
+// Returns the path in the worktree corresponding to `path` and a function
+// removing the worktree.
+func Worktree(path string, ref string) (worktreePath string, remove func() error, err error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	topLevel, err := gitOutput(absPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", nil, err
+	}
+	// Resolve symlinks such that the path is relative to the top level git reports
+	if absPath, err = filepath.EvalSymlinks(absPath); err != nil {
+		return "", nil, err
+	}
+	relativePath, err := filepath.Rel(topLevel, absPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	worktreeDir, err := ioutil.TempDir("", "fabrikate-worktree")
+	if err != nil {
+		return "", nil, err
+	}
+
+	logger.Info(emoji.Sprintf(":evergreen_tree: Checking out '%s' of '%s' into '%s'", ref, topLevel, worktreeDir))
+	if _, err := gitOutput(topLevel, "worktree", "add", "--detach", worktreeDir, ref); err != nil {
+		os.RemoveAll(worktreeDir)
+		return "", nil, err
+	}
+
+	remove = func() error {
+		_, err := gitOutput(topLevel, "worktree", "remove", "--force", worktreeDir)
+		return err
+	}
+
+	return filepath.Join(worktreeDir, relativePath), remove, nil
+}
+
+// gitOutput runs git with `args` in `dir` and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("`git %s` failed: %w: %s", strings.Join(args, " "), err, stderr.String())
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
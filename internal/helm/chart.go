@@ -0,0 +1,26 @@
+package helm
+
+import (
+	"io/ioutil"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChartVersion returns the version declared in the Chart.yaml of the chart
+// located at `chartPath`.
+func ChartVersion(chartPath string) (string, error) {
+	bytes, err := ioutil.ReadFile(path.Join(chartPath, "Chart.yaml"))
+	if err != nil {
+		return "", err
+	}
+
+	chart := struct {
+		Version string
+	}{}
+	if err := yaml.Unmarshal(bytes, &chart); err != nil {
+		return "", err
+	}
+
+	return chart.Version, nil
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
@@ -16,21 +15,76 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// DependencyUpdate attempts to run `helm dependency update` on chartPath
-func DependencyUpdate(chartPath string) (err error) {
-	// A single helm dependency entry
-	type helmDependency struct {
-		Name       string
-		Version    string
-		Repository string
-		Condition  string
+// Dependency is a single helm dependency entry of a chart
+type Dependency struct {
+	Name       string
+	Version    string
+	Repository string
+	Condition  string
+}
+
+// dependenciesPath returns the path to the file declaring the dependencies of
+// the chart at `absChartPath`.
+// For both api versions v1 and v2, if requirements.yaml has dependencies
+// Chart.yaml's dependencies will be ignored.
+func dependenciesPath(absChartPath string) string {
+	dependenciesYamlPath := path.Join(absChartPath, "requirements.yaml")
+	if _, err := os.Stat(dependenciesYamlPath); err != nil {
+		dependenciesYamlPath = path.Join(absChartPath, "Chart.yaml")
 	}
 
+	return dependenciesYamlPath
+}
+
+// Dependencies returns the dependencies declared by the chart at `chartPath`
+// in requirements.yaml or Chart.yaml.
+func Dependencies(chartPath string) (dependencies []Dependency, err error) {
 	// Contents of requirements.yaml or dependencies in Chart.yaml
 	type helmDependencies struct {
-		Dependencies []helmDependency
+		Dependencies []Dependency
+	}
+
+	dependenciesYamlPath := dependenciesPath(chartPath)
+	if _, err := os.Stat(dependenciesYamlPath); err != nil {
+		return dependencies, nil
+	}
+
+	bytes, err := ioutil.ReadFile(dependenciesYamlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dependenciesYaml := helmDependencies{}
+	if err = yaml.Unmarshal(bytes, &dependenciesYaml); err != nil {
+		return nil, err
+	}
+
+	return dependenciesYaml.Dependencies, nil
+}
+
+// DependenciesDownloaded returns whether the chart at `chartPath` exists and
+// has no dependencies or has its dependencies downloaded into its `charts`
+// directory.
+func DependenciesDownloaded(chartPath string) (bool, error) {
+	if _, err := os.Stat(path.Join(chartPath, "Chart.yaml")); os.IsNotExist(err) {
+		return false, nil
 	}
 
+	dependencies, err := Dependencies(chartPath)
+	if err != nil || len(dependencies) == 0 {
+		return err == nil, err
+	}
+
+	downloaded, err := ioutil.ReadDir(path.Join(chartPath, "charts"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return len(downloaded) > 0, err
+}
+
+// DependencyUpdate attempts to run `helm dependency update` on chartPath
+func DependencyUpdate(chartPath string) (err error) {
 	absChartPath := chartPath
 	if isAbs := filepath.IsAbs(absChartPath); !isAbs {
 		asAbs, err := filepath.Abs(chartPath)
@@ -41,28 +95,17 @@ func DependencyUpdate(chartPath string) (err error) {
 	}
 
 	// Parse chart dependency repositories and add them if not present.
-	// For both api versions v1 and v2, if requirements.yaml has dependencies
-	// Chart.yaml's dependencies will be ignored.
-	dependenciesYamlPath := path.Join(absChartPath, "requirements.yaml")
-	if _, err := os.Stat(dependenciesYamlPath); err != nil {
-		dependenciesYamlPath = path.Join(absChartPath, "Chart.yaml")
+	dependencies, err := Dependencies(absChartPath)
+	if err != nil {
+		return err
 	}
 	addedDepRepoList := []string{}
-	if _, err := os.Stat(dependenciesYamlPath); err == nil {
+	if len(dependencies) > 0 {
+		dependenciesYamlPath := dependenciesPath(absChartPath)
 		logger.Info(fmt.Sprintf("'%s' found at '%s', ensuring repositories exist on helm client", filepath.Base(dependenciesYamlPath), dependenciesYamlPath))
 
-		bytes, err := ioutil.ReadFile(dependenciesYamlPath)
-		if err != nil {
-			return err
-		}
-
-		dependenciesYaml := helmDependencies{}
-		if err = yaml.Unmarshal(bytes, &dependenciesYaml); err != nil {
-			return err
-		}
-
 		// Add each dependency repo with a temp name
-		for _, dep := range dependenciesYaml.Dependencies {
+		for _, dep := range dependencies {
 			currentRepo, _ := FindRepoNameByURL(dep.Repository)
 			if currentRepo != "" {
 				logger.Info(emoji.Sprintf(":pencil: Helm dependency repo already present: %v", currentRepo))
@@ -90,7 +133,10 @@ func DependencyUpdate(chartPath string) (err error) {
 	}
 
 	logger.Info(emoji.Sprintf(":helicopter: Updating helm chart's dependencies for chart in '%s'", absChartPath))
-	updateCmd := exec.Command("helm", "dependency", "update", chartPath)
+	updateCmd, err := Command("dependency", "update", chartPath)
+	if err != nil {
+		return err
+	}
 	var stderr bytes.Buffer
 	updateCmd.Stderr = &stderr
 	if err := updateCmd.Run(); err != nil {
@@ -0,0 +1,106 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/microsoft/fabrikate/internal/logger"
+)
+
+// Thread safe store of the helm environment private to this run of Fabrikate
+type helmEnv struct {
+	mu  sync.Mutex
+	dir string
+}
+
+var env = helmEnv{}
+
+// environmentDir returns the directory of the helm repository config and
+// cache private to this run of Fabrikate, creating it on first use. The host
+// repository config is copied into it, such that the repositories added on
+// the host can be used, but those added by Fabrikate never show up in the host
+// `helm repo list` and concurrent runs do not see each other's repositories.
+func environmentDir() (string, error) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	if env.dir != "" {
+		return env.dir, nil
+	}
+
+	dir, err := ioutil.TempDir("", "fabrikate-helm")
+	if err != nil {
+		return "", err
+	}
+	if hostConfig, err := ioutil.ReadFile(hostRepositoryConfig()); err == nil {
+		if err := ioutil.WriteFile(path.Join(dir, "repositories.yaml"), hostConfig, 0600); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	logger.Debug("Using helm repository config and cache in " + dir)
+
+	env.dir = dir
+	return dir, nil
+}
+
+// hostRepositoryConfig returns the path of the helm repository config of the
+// host, as helm finds it.
+func hostRepositoryConfig() string {
+	if config := os.Getenv("HELM_REPOSITORY_CONFIG"); config != "" {
+		return config
+	}
+	if configHome := os.Getenv("HELM_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "repositories.yaml")
+	}
+
+	home, _ := os.UserHomeDir()
+	configHome := filepath.Join(home, ".config")
+	switch {
+	case os.Getenv("XDG_CONFIG_HOME") != "":
+		configHome = os.Getenv("XDG_CONFIG_HOME")
+	case runtime.GOOS == "darwin":
+		configHome = filepath.Join(home, "Library", "Preferences")
+	case runtime.GOOS == "windows":
+		configHome = os.Getenv("APPDATA")
+	}
+
+	return filepath.Join(configHome, "helm", "repositories.yaml")
+}
+
+// Command returns the command running `helm` with `args` with the repository
+// config and cache private to this run of Fabrikate (see environmentDir).
+func Command(args ...string) (*exec.Cmd, error) {
+	dir, err := environmentDir()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("helm", args...)
+	cmd.Env = append(os.Environ(),
+		"HELM_REPOSITORY_CONFIG="+path.Join(dir, "repositories.yaml"),
+		"HELM_REPOSITORY_CACHE="+path.Join(dir, "repository"),
+		"HELM_CACHE_HOME="+path.Join(dir, "cache"))
+
+	return cmd, nil
+}
+
+// Cleanup removes the helm repository config and cache private to this run of
+// Fabrikate, if any were created.
+func Cleanup() error {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	if env.dir == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(env.dir)
+	env.dir = ""
+	return err
+}
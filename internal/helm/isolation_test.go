@@ -0,0 +1,55 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// The host repositories are copied into the repository config of the run
+	hostConfig := path.Join(dir, "repositories.yaml")
+	assert.Nil(t, ioutil.WriteFile(hostConfig, []byte("repositories:\n- name: stable\n  url: https://charts.example.com\n"), 0644))
+	os.Setenv("HELM_REPOSITORY_CONFIG", hostConfig)
+	defer os.Unsetenv("HELM_REPOSITORY_CONFIG")
+
+	cmd, err := Command("repo", "add", "fabrikate", "https://fabrikate.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"helm", "repo", "add", "fabrikate", "https://fabrikate.example.com"}, cmd.Args)
+
+	environment := map[string]string{}
+	for _, variable := range cmd.Env {
+		for _, name := range []string{"HELM_REPOSITORY_CONFIG=", "HELM_REPOSITORY_CACHE=", "HELM_CACHE_HOME="} {
+			if strings.HasPrefix(variable, name) {
+				environment[name] = strings.TrimPrefix(variable, name)
+			}
+		}
+	}
+	assert.Equal(t, 3, len(environment))
+	repositoryConfig := environment["HELM_REPOSITORY_CONFIG="]
+	assert.NotEqual(t, hostConfig, repositoryConfig)
+	copied, err := ioutil.ReadFile(repositoryConfig)
+	assert.Nil(t, err)
+	assert.Contains(t, string(copied), "https://charts.example.com")
+
+	// Every command of the run shares it
+	again, err := Command("repo", "list")
+	assert.Nil(t, err)
+	assert.Contains(t, again.Env, "HELM_REPOSITORY_CONFIG="+repositoryConfig)
+
+	// until cleaned up
+	assert.Nil(t, Cleanup())
+	_, err = os.Stat(repositoryConfig)
+	assert.True(t, os.IsNotExist(err))
+	host, err := ioutil.ReadFile(hostConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, string(copied), string(host))
+}
@@ -1,39 +1,230 @@
 package helm
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kyokomi/emoji"
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/microsoft/fabrikate/internal/logger"
 )
 
-// Pull will do a `helm pull` for the target chart and extract the chart to
-// `into`.
+// PullCached extracts version `version` of the target chart from the install
+// cache to `into`, erroring with cache.ErrNotCached if it is not cached.
 // Note that the directory structure will look like: <into>/<chart>/Chart.yaml
-func Pull(repoURL string, chart string, version string, into string) error {
+//
+// Returns the sha256 digest of the cached chart archive.
+func PullCached(repoURL string, chart string, version string, into string) (digest string, err error) {
+	cachedPath, err := cache.HelmPath(repoURL, chart, version)
+	if err != nil {
+		return "", err
+	}
+	if len(version) == 0 || !cache.Lookup(cachedPath) {
+		return "", fmt.Errorf("%w: helm chart '%s' version '%s' from '%s'", cache.ErrNotCached, chart, version, repoURL)
+	}
+
+	logger.Info(emoji.Sprintf(":atm: Found helm chart '%s' version '%s' in install cache '%s'; skipping pull", chart, version, cachedPath))
+	archive := path.Join(cachedPath, cache.HelmArchiveFilename)
+	if digest, err = fileDigest(archive); err != nil {
+		return "", err
+	}
+
+	return digest, untar(archive, into)
+}
+
+// IsOCI returns whether `repoURL` points to an OCI registry rather than a helm
+// chart repository.
+func IsOCI(repoURL string) bool {
+	return strings.HasPrefix(repoURL, "oci://")
+}
+
+// IsDigest returns whether `version` is the `sha256:` digest of a chart in an
+// OCI registry rather than a chart version.
+func IsDigest(version string) bool {
+	return strings.HasPrefix(version, "sha256:")
+}
+
+// pullArgs returns the arguments to `helm pull` the target chart into
+// `destination`. Charts in OCI registries are pulled by reference; `version`
+// is either a tag or a `sha256:` digest.
+func pullArgs(repoURL string, chart string, version string, destination string) []string {
+	if IsOCI(repoURL) {
+		chartRef := strings.TrimSuffix(repoURL, "/") + "/" + chart
+		if IsDigest(version) {
+			return []string{"pull", chartRef + "@" + version, "--destination", destination}
+		}
+		return []string{"pull", chartRef, "--version", version, "--destination", destination}
+	}
+
 	// check if existing repo with same URL in host client
+	chartRef := chart
 	existingRepo, _ := FindRepoNameByURL(repoURL)
 	if len(existingRepo) > 0 {
-		chart = existingRepo + "/" + chart
+		chartRef = existingRepo + "/" + chart
 	}
 
 	// arguments don't include --repo by default
-	pullArgs := []string{"pull", chart,
+	args := []string{"pull", chartRef,
 		"--version", version,
-		"--untar",
-		"--untardir", into}
+		"--destination", destination}
 
 	// use the --repo option to pull directly from URL if repo not on host Helm
 	if len(existingRepo) == 0 {
-		pullArgs = append(pullArgs, "--repo", repoURL)
+		args = append(args, "--repo", repoURL)
+	}
+
+	return args
+}
+
+// Pull will do a `helm pull` for the target chart and extract the chart to
+// `into`. `repoURL` is either a helm chart repository or an `oci://` registry.
+// Pulled chart archives are stored in the install cache by version; if
+// `version` is already in the install cache, the cached archive is used
+// instead.
+// Note that the directory structure will look like: <into>/<chart>/Chart.yaml,
+// where <chart> is the last segment of `chart` for charts in OCI registries.
+//
+// Returns the sha256 digest of the pulled chart archive.
+func Pull(repoURL string, chart string, version string, into string) (digest string, err error) {
+	// check if the chart version was pulled by a previous run
+	if digest, err = PullCached(repoURL, chart, version, into); !errors.Is(err, cache.ErrNotCached) {
+		return digest, err
+	}
+
+	// pull the archive into the install cache so it can be hashed before extraction
+	archiveDir, err := cache.TempDir()
+	if err != nil {
+		return "", err
 	}
+	defer os.RemoveAll(archiveDir)
 
-	cmd := exec.Command("helm", pullArgs...)
+	cmd, err := Command(pullArgs(repoURL, chart, version, archiveDir)...)
+	if err != nil {
+		return "", err
+	}
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("%w: %v", err, stderr.String())
+		return "", fmt.Errorf("%w: %v", err, stderr.String())
+	}
+
+	archives, err := filepath.Glob(path.Join(archiveDir, "*.tgz"))
+	if err != nil {
+		return "", err
+	}
+	if len(archives) != 1 {
+		return "", fmt.Errorf("expected `helm pull` of chart '%s' from '%s' to produce a single archive; found %d", chart, repoURL, len(archives))
+	}
+	archive := path.Join(archiveDir, cache.HelmArchiveFilename)
+	if err := os.Rename(archives[0], archive); err != nil {
+		return "", err
 	}
 
-	return nil
+	if digest, err = fileDigest(archive); err != nil {
+		return "", err
+	}
+	if err = untar(archive, into); err != nil {
+		return "", err
+	}
+
+	// Store the archive in the install cache under the version it resolved to; charts pulled by digest
+	// under the digest, which PullCached is given again
+	cachedVersion := version
+	if !IsDigest(version) {
+		if cachedVersion, err = ChartVersion(path.Join(into, path.Base(chart))); err != nil {
+			return "", err
+		}
+	}
+	cachedPath, err := cache.HelmPath(repoURL, chart, cachedVersion)
+	if err != nil {
+		return "", err
+	}
+
+	return digest, cache.Store(archiveDir, cachedPath)
+}
+
+// fileDigest returns the sha256 digest of the file at `filePath` in the form
+// `sha256:<hex>`.
+func fileDigest(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)), nil
+}
+
+// untar extracts the gzipped tarball `archive` into the directory `into`.
+func untar(archive string, into string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	absInto, err := filepath.Abs(into)
+	if err != nil {
+		return err
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Refuse to write anything outside of the target directory
+		target := filepath.Join(absInto, header.Name)
+		if !strings.HasPrefix(target, absInto+string(os.PathSeparator)) {
+			return fmt.Errorf("chart archive '%s' contains invalid path '%s'", archive, header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tarReader); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
 }
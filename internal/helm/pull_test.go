@@ -0,0 +1,23 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullArgsOCI(t *testing.T) {
+	assert.True(t, IsOCI("oci://registry.example.com/charts"))
+	assert.False(t, IsOCI("https://charts.example.com"))
+
+	// By tag
+	assert.Equal(t,
+		[]string{"pull", "oci://registry.example.com/charts/podinfo", "--version", "6.0.0", "--destination", "/tmp/charts"},
+		pullArgs("oci://registry.example.com/charts/", "podinfo", "6.0.0", "/tmp/charts"))
+
+	// By digest
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	assert.Equal(t,
+		[]string{"pull", "oci://registry.example.com/charts/podinfo@" + digest, "--destination", "/tmp/charts"},
+		pullArgs("oci://registry.example.com/charts", "podinfo", digest, "/tmp/charts"))
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 )
 
 // RepoListEntry is a single entry from the output of
@@ -14,12 +13,16 @@ type RepoListEntry struct {
 	URL  string `json:"url"`
 }
 
-// RepoList lists all repositories currently in the host Helm client
+// RepoList lists all repositories currently in the helm repository config of
+// this run (see Command)
 func RepoList() (list []RepoListEntry, err error) {
 	lock.RLock()
 	defer lock.RUnlock()
 
-	listCmd := exec.Command("helm", "repo", "list", "--output", "json")
+	listCmd, err := Command("repo", "list", "--output", "json")
+	if err != nil {
+		return list, err
+	}
 	var stdout, stderr bytes.Buffer
 	listCmd.Stdout = &stdout
 	listCmd.Stderr = &stderr
@@ -34,13 +37,16 @@ func RepoList() (list []RepoListEntry, err error) {
 	return list, err
 }
 
-// RepoAdd adds a helm repository of `name` pointing to `url` to the host Helm
-// client
+// RepoAdd adds a helm repository of `name` pointing to `url` to the helm
+// repository config of this run, leaving that of the host untouched
 func RepoAdd(name string, url string) error {
 	lock.Lock()
 	defer lock.Unlock()
 
-	addCmd := exec.Command("helm", "repo", "add", name, url)
+	addCmd, err := Command("repo", "add", name, url)
+	if err != nil {
+		return err
+	}
 	var stdout, stderr bytes.Buffer
 	addCmd.Stdout = &stdout
 	addCmd.Stderr = &stderr
@@ -51,13 +57,16 @@ func RepoAdd(name string, url string) error {
 	return nil
 }
 
-// RepoRemove attempts to remove the helm repository of `name` from the host
-// helm client
+// RepoRemove attempts to remove the helm repository of `name` from the helm
+// repository config of this run
 func RepoRemove(name string) error {
 	lock.Lock()
 	defer lock.Unlock()
 
-	removeCmd := exec.Command("helm", "repo", "remove", name)
+	removeCmd, err := Command("repo", "remove", name)
+	if err != nil {
+		return err
+	}
 	var stdout, stderr bytes.Buffer
 	removeCmd.Stdout = &stdout
 	removeCmd.Stderr = &stderr
@@ -68,8 +77,8 @@ func RepoRemove(name string) error {
 	return nil
 }
 
-// FindRepoNameByURL attempts to search for an existing helm repository on the
-// the host matching the provided URL.
+// FindRepoNameByURL attempts to search for an existing helm repository of
+// this run, including those of the host, matching the provided URL.
 // Will return the the name of the repo if found or empty string if not.
 // Errors when unable to parse the host repository list.
 func FindRepoNameByURL(URL string) (string, error) {
@@ -3,7 +3,6 @@ package helm
 import (
 	"bytes"
 	"fmt"
-	"os/exec"
 )
 
 // TemplateOptions encapsulate the options for `helm template`
@@ -28,7 +27,10 @@ func Template(opts TemplateOptions) (string, error) {
 		templateArgs = append(templateArgs, "--values", yamlPath)
 	}
 
-	templateCmd := exec.Command("helm", templateArgs...)
+	templateCmd, err := Command(templateArgs...)
+	if err != nil {
+		return "", err
+	}
 	var stdout, stderr bytes.Buffer
 	templateCmd.Stdout = &stdout
 	templateCmd.Stderr = &stderr
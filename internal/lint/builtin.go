@@ -0,0 +1,203 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/fabrikate/internal/manifest"
+)
+
+// BuiltinRules returns the rules shipped with Fabrikate.
+func BuiltinRules() []Rule {
+	return []Rule{
+		noLatestTagRule{},
+		resourceLimitsRule{},
+		noPrivilegedRule{},
+		namespaceRequiredRule{},
+		noDuplicatesRule{},
+	}
+}
+
+// podSpecPaths are the paths of the pod spec within the objects of the
+// built-in kinds running pods.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// podSpec returns the pod spec of `object` and its path; nil if it does not run pods.
+func podSpec(object manifest.Object) (spec map[string]interface{}, path string) {
+	specPath, ok := podSpecPaths[object.Identity().Kind]
+	if !ok {
+		return nil, ""
+	}
+
+	value := map[string]interface{}(object)
+	for _, key := range specPath {
+		if value, ok = value[key].(map[string]interface{}); !ok {
+			return nil, ""
+		}
+	}
+
+	return value, strings.Join(specPath, ".")
+}
+
+// container is a container of a pod spec, along with its path.
+type container struct {
+	spec map[string]interface{}
+	path string
+}
+
+// containers returns the containers and init containers of the pods run by `object`.
+func containers(object manifest.Object) (found []container) {
+	spec, specPath := podSpec(object)
+	for _, key := range []string{"initContainers", "containers"} {
+		list, _ := spec[key].([]interface{})
+		for index, item := range list {
+			if containerSpec, ok := item.(map[string]interface{}); ok {
+				found = append(found, container{spec: containerSpec, path: fmt.Sprintf("%s.%s[%d]", specPath, key, index)})
+			}
+		}
+	}
+
+	return found
+}
+
+// noLatestTagRule requires container images to be pinned to a tag other than latest, or a digest.
+type noLatestTagRule struct{}
+
+func (noLatestTagRule) ID() string { return "no-latest-tag" }
+func (noLatestTagRule) Description() string {
+	return "Container images are pinned to a tag other than latest, or to a digest."
+}
+func (noLatestTagRule) Severity() string { return SeverityError }
+
+func (r noLatestTagRule) Check(targets []Target) (findings []Finding) {
+	for _, target := range targets {
+		for _, c := range containers(target.Object) {
+			image, _ := c.spec["image"].(string)
+			if strings.Contains(image, "@") {
+				continue
+			}
+
+			// The tag follows the last colon after the registry host and port, if any
+			tag := ""
+			name := image[strings.LastIndex(image, "/")+1:]
+			if colon := strings.LastIndex(name, ":"); colon >= 0 {
+				tag = name[colon+1:]
+			}
+
+			switch tag {
+			case "":
+				findings = append(findings, newFinding(r, target, c.path+".image", fmt.Sprintf("image '%s' has no tag", image)))
+			case "latest":
+				findings = append(findings, newFinding(r, target, c.path+".image", fmt.Sprintf("image '%s' uses the latest tag", image)))
+			}
+		}
+	}
+
+	return findings
+}
+
+// resourceLimitsRule requires containers to set cpu and memory limits.
+type resourceLimitsRule struct{}
+
+func (resourceLimitsRule) ID() string          { return "resource-limits" }
+func (resourceLimitsRule) Description() string { return "Containers set cpu and memory limits." }
+func (resourceLimitsRule) Severity() string    { return SeverityError }
+
+func (r resourceLimitsRule) Check(targets []Target) (findings []Finding) {
+	for _, target := range targets {
+		for _, c := range containers(target.Object) {
+			resources, _ := c.spec["resources"].(map[string]interface{})
+			limits, _ := resources["limits"].(map[string]interface{})
+			missing := []string{}
+			for _, resource := range []string{"cpu", "memory"} {
+				if _, ok := limits[resource]; !ok {
+					missing = append(missing, resource)
+				}
+			}
+
+			if len(missing) > 0 {
+				findings = append(findings, newFinding(r, target, c.path+".resources.limits", fmt.Sprintf("container '%v' sets no %s limit", c.spec["name"], strings.Join(missing, " or "))))
+			}
+		}
+	}
+
+	return findings
+}
+
+// noPrivilegedRule forbids privileged containers.
+type noPrivilegedRule struct{}
+
+func (noPrivilegedRule) ID() string          { return "no-privileged" }
+func (noPrivilegedRule) Description() string { return "Containers do not run privileged." }
+func (noPrivilegedRule) Severity() string    { return SeverityError }
+
+func (r noPrivilegedRule) Check(targets []Target) (findings []Finding) {
+	for _, target := range targets {
+		for _, c := range containers(target.Object) {
+			securityContext, _ := c.spec["securityContext"].(map[string]interface{})
+			if privileged, _ := securityContext["privileged"].(bool); privileged {
+				findings = append(findings, newFinding(r, target, c.path+".securityContext.privileged", fmt.Sprintf("container '%v' runs privileged", c.spec["name"])))
+			}
+		}
+	}
+
+	return findings
+}
+
+// namespaceRequiredRule requires namespaced objects to set their namespace.
+type namespaceRequiredRule struct{}
+
+func (namespaceRequiredRule) ID() string { return "namespace-required" }
+func (namespaceRequiredRule) Description() string {
+	return "Namespaced objects set their namespace rather than rely on the namespace they are applied to."
+}
+func (namespaceRequiredRule) Severity() string { return SeverityError }
+
+func (r namespaceRequiredRule) Check(targets []Target) (findings []Finding) {
+	objects := []manifest.Object{}
+	for _, target := range targets {
+		objects = append(objects, target.Object)
+	}
+	clusterScoped := manifest.ClusterScopedKinds(objects)
+
+	for _, target := range targets {
+		id := target.Object.Identity()
+		if id.Namespace == "" && !clusterScoped[id.Kind] {
+			findings = append(findings, newFinding(r, target, "metadata.namespace", fmt.Sprintf("%s has no namespace", id.Kind)))
+		}
+	}
+
+	return findings
+}
+
+// noDuplicatesRule forbids several objects with the same apiVersion, kind, namespace, and name.
+type noDuplicatesRule struct{}
+
+func (noDuplicatesRule) ID() string { return "no-duplicates" }
+func (noDuplicatesRule) Description() string {
+	return "No two generated objects have the same apiVersion, kind, namespace, and name."
+}
+func (noDuplicatesRule) Severity() string { return SeverityError }
+
+func (r noDuplicatesRule) Check(targets []Target) (findings []Finding) {
+	first := map[manifest.Identity]Target{}
+	for _, target := range targets {
+		id := target.Object.Identity()
+		if original, duplicate := first[id]; duplicate {
+			findings = append(findings, newFinding(r, target, "", fmt.Sprintf("object is also generated by component '%s' in %s", original.Component, original.File)))
+			continue
+		}
+		first[id] = target
+	}
+
+	return findings
+}
@@ -0,0 +1,392 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the lint configuration of a definition: the built-in rules to
+// disable and the custom rules to add.
+type Config struct {
+	Disable []string     `yaml:"disable,omitempty"`
+	Rules   []CustomRule `yaml:"rules,omitempty"`
+}
+
+// CustomRule is a declarative rule: every object matched by Match has to
+// satisfy all of the assertions.
+type CustomRule struct {
+	RuleID       string      `yaml:"id"`
+	Details      string      `yaml:"description"`
+	RuleSeverity string      `yaml:"severity,omitempty"`
+	Match        Match       `yaml:"match,omitempty"`
+	Assert       []Assertion `yaml:"assert"`
+}
+
+// Match selects the objects a custom rule applies to; empty lists match all.
+type Match struct {
+	APIVersions []string `yaml:"apiVersions,omitempty"`
+	Kinds       []string `yaml:"kinds,omitempty"`
+}
+
+// Assertion is a condition on the values at a JSONPath-style path of an
+// object, eg. `$.spec.template.spec.containers[*].image`. The values have to
+// satisfy every check which is set; values which are not set satisfy all but
+// `exists: true`.
+type Assertion struct {
+	Path    string        `yaml:"path"`
+	Exists  *bool         `yaml:"exists,omitempty"`
+	Equals  interface{}   `yaml:"equals,omitempty"`
+	OneOf   []interface{} `yaml:"oneOf,omitempty"`
+	Matches string        `yaml:"matches,omitempty"`
+
+	parsed  []pathPart
+	pattern *regexp.Regexp
+}
+
+// LoadConfig loads the lint configuration in the YAML file at `configPath`.
+func LoadConfig(configPath string) (config Config, err error) {
+	configYAML, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return config, err
+	}
+
+	decoder := yaml.NewDecoder(strings.NewReader(string(configYAML)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("error parsing lint config '%s': %w", configPath, err)
+	}
+
+	for index := range config.Rules {
+		if err := config.Rules[index].compile(); err != nil {
+			return config, fmt.Errorf("invalid rule in lint config '%s': %w", configPath, err)
+		}
+	}
+
+	return config, nil
+}
+
+// Rules returns the built-in rules which are not disabled, followed by the
+// custom rules of the configs.
+func Rules(configs ...Config) (rules []Rule, err error) {
+	disabled := map[string]bool{}
+	for _, config := range configs {
+		for _, id := range config.Disable {
+			disabled[id] = true
+		}
+	}
+
+	ids := map[string]bool{}
+	for _, rule := range BuiltinRules() {
+		ids[rule.ID()] = true
+		if !disabled[rule.ID()] {
+			rules = append(rules, rule)
+		}
+	}
+
+	for _, config := range configs {
+		for _, rule := range config.Rules {
+			if ids[rule.RuleID] {
+				return nil, fmt.Errorf("rule '%s' is defined more than once", rule.RuleID)
+			}
+			ids[rule.RuleID] = true
+			if !disabled[rule.RuleID] {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	for id := range disabled {
+		if !ids[id] {
+			return nil, fmt.Errorf("cannot disable unknown rule '%s'", id)
+		}
+	}
+
+	return rules, nil
+}
+
+// compile validates the rule and parses the paths and patterns of its assertions.
+func (r *CustomRule) compile() (err error) {
+	if r.RuleID == "" {
+		return fmt.Errorf("rule has no id")
+	}
+	switch r.RuleSeverity {
+	case "":
+		r.RuleSeverity = SeverityError
+	case SeverityError, SeverityWarning:
+	default:
+		return fmt.Errorf("rule '%s' has severity '%s', expected %s or %s", r.RuleID, r.RuleSeverity, SeverityError, SeverityWarning)
+	}
+	if len(r.Assert) == 0 {
+		return fmt.Errorf("rule '%s' has no assertions", r.RuleID)
+	}
+
+	for index := range r.Assert {
+		assertion := &r.Assert[index]
+		if assertion.parsed, err = parsePath(assertion.Path); err != nil {
+			return fmt.Errorf("rule '%s': %w", r.RuleID, err)
+		}
+		if assertion.Matches != "" {
+			if assertion.pattern, err = regexp.Compile(assertion.Matches); err != nil {
+				return fmt.Errorf("rule '%s': %w", r.RuleID, err)
+			}
+		}
+		if assertion.Exists == nil && assertion.Equals == nil && assertion.OneOf == nil && assertion.pattern == nil {
+			return fmt.Errorf("rule '%s': assertion on '%s' checks nothing, expected one of exists, equals, oneOf, or matches", r.RuleID, assertion.Path)
+		}
+	}
+
+	return nil
+}
+
+// ID implements Rule.
+func (r CustomRule) ID() string { return r.RuleID }
+
+// Description implements Rule.
+func (r CustomRule) Description() string { return r.Details }
+
+// Severity implements Rule.
+func (r CustomRule) Severity() string { return r.RuleSeverity }
+
+// Check implements Rule.
+func (r CustomRule) Check(targets []Target) (findings []Finding) {
+	for _, target := range targets {
+		if !r.Match.matches(target) {
+			continue
+		}
+
+		for _, assertion := range r.Assert {
+			for _, violation := range assertion.check(target.Object) {
+				message := violation.message
+				if r.Details != "" {
+					message = fmt.Sprintf("%s: %s", r.Details, message)
+				}
+				findings = append(findings, newFinding(r, target, violation.path, message))
+			}
+		}
+	}
+
+	return findings
+}
+
+// matches returns whether the object of `target` is selected.
+func (m Match) matches(target Target) bool {
+	id := target.Object.Identity()
+	return (len(m.APIVersions) == 0 || contains(m.APIVersions, id.APIVersion)) && (len(m.Kinds) == 0 || contains(m.Kinds, id.Kind))
+}
+
+// contains returns whether `values` contains `value`.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// assertionViolation is a value failing an assertion.
+type assertionViolation struct {
+	path    string
+	message string
+}
+
+// check returns the values of `object` failing the assertion.
+func (a Assertion) check(object map[string]interface{}) (violations []assertionViolation) {
+	found, missing := evaluatePath(object, a.parsed)
+
+	if a.Exists != nil && *a.Exists {
+		for _, path := range missing {
+			violations = append(violations, assertionViolation{path, "not set"})
+		}
+	}
+
+	for _, value := range found {
+		switch {
+		case a.Exists != nil && !*a.Exists:
+			violations = append(violations, assertionViolation{value.path, "set"})
+		case a.Equals != nil && !reflect.DeepEqual(value.value, a.Equals):
+			violations = append(violations, assertionViolation{value.path, fmt.Sprintf("%s, expected %s", formatValue(value.value), formatValue(a.Equals))})
+		case a.OneOf != nil && !oneOf(value.value, a.OneOf):
+			violations = append(violations, assertionViolation{value.path, fmt.Sprintf("%s, expected one of %s", formatValue(value.value), formatValue(a.OneOf))})
+		case a.pattern != nil && !a.pattern.MatchString(fmt.Sprint(value.value)):
+			violations = append(violations, assertionViolation{value.path, fmt.Sprintf("%s, expected to match %s", formatValue(value.value), a.Matches)})
+		}
+	}
+
+	return violations
+}
+
+// oneOf returns whether `value` is one of `values`.
+func oneOf(value interface{}, values []interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(value, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatValue formats a value as compact JSON.
+func formatValue(value interface{}) string {
+	formatted, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(formatted)
+}
+
+// pathPart is a part of a parsed path: a map key, a list index, or a
+// wildcard matching all items of a map or list.
+type pathPart struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parsePath parses a JSONPath-style path: an optional leading `$`, then
+// `.key`, `["key"]`, `[N]`, `[*]` or `.*` parts.
+func parsePath(path string) (parts []pathPart, err error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if rest == "" {
+		return nil, fmt.Errorf("empty path '%s'", path)
+	}
+	if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "[") {
+		rest = "." + rest
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			key := rest[1:end]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in path '%s'", path)
+			}
+			parts = append(parts, pathPart{key: key, wildcard: key == "*"})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' in path '%s'", path)
+			}
+			inner := rest[1:end]
+			switch {
+			case inner == "*":
+				parts = append(parts, pathPart{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				parts = append(parts, pathPart{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index '[%s]' in path '%s'", inner, path)
+				}
+				parts = append(parts, pathPart{index: index, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in path '%s'", rest[0], path)
+		}
+	}
+
+	return parts, nil
+}
+
+// pathValue is a value found at a path, along with its concrete path.
+type pathValue struct {
+	value interface{}
+	path  string
+}
+
+// evaluatePath returns the values at `parts` within `value`, and the concrete
+// paths at which the path is not set.
+func evaluatePath(value interface{}, parts []pathPart) (found []pathValue, missing []string) {
+	var evaluate func(value interface{}, parts []pathPart, path string)
+	evaluate = func(value interface{}, parts []pathPart, path string) {
+		if len(parts) == 0 {
+			found = append(found, pathValue{value: value, path: path})
+			return
+		}
+
+		part := parts[0]
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			if part.wildcard {
+				for _, key := range sortedKeys(typed) {
+					evaluate(typed[key], parts[1:], joinPath(path, key))
+				}
+				return
+			}
+			if item, ok := typed[part.key]; ok && !part.isIndex {
+				evaluate(item, parts[1:], joinPath(path, part.key))
+				return
+			}
+		case []interface{}:
+			if part.wildcard {
+				for index, item := range typed {
+					evaluate(item, parts[1:], fmt.Sprintf("%s[%d]", path, index))
+				}
+				return
+			}
+			if part.isIndex && part.index < len(typed) {
+				evaluate(typed[part.index], parts[1:], fmt.Sprintf("%s[%d]", path, part.index))
+				return
+			}
+		}
+
+		missing = append(missing, joinPath(path, formatPathParts(parts)))
+	}
+
+	evaluate(value, parts, "")
+	return found, missing
+}
+
+// joinPath appends `key` to `path`.
+func joinPath(path string, key string) string {
+	if path == "" || strings.HasPrefix(key, "[") {
+		return path + key
+	}
+
+	return path + "." + key
+}
+
+// formatPathParts formats parsed path parts, eg. `containers[*].image`.
+func formatPathParts(parts []pathPart) (path string) {
+	for _, part := range parts {
+		switch {
+		case part.isIndex:
+			path += fmt.Sprintf("[%d]", part.index)
+		case part.wildcard && part.key == "":
+			path += "[*]"
+		default:
+			path = joinPath(path, part.key)
+		}
+	}
+
+	return path
+}
+
+// sortedKeys returns the keys of `m` in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
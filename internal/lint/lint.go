@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/microsoft/fabrikate/internal/manifest"
+)
+
+// Severities of the findings of rules.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Target is a generated object to lint, along with the component which
+// generated it and the file it is generated to.
+type Target struct {
+	Component string
+	File      string
+	Object    manifest.Object
+}
+
+// Finding is a violation of a rule by a generated object.
+type Finding struct {
+	RuleID    string `json:"ruleId"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Component string `json:"component"`
+	File      string `json:"file"`
+	Object    string `json:"object"`
+	Path      string `json:"path,omitempty"`
+}
+
+// String returns the finding in the form `<severity> <rule>: <file>: <object>: <path>: <message>`.
+func (f Finding) String() string {
+	location := f.File
+	if f.Object != "" {
+		location = fmt.Sprintf("%s: %s", location, f.Object)
+	}
+	if f.Path != "" {
+		location = fmt.Sprintf("%s: %s", location, f.Path)
+	}
+
+	return fmt.Sprintf("%s %s: %s: %s", f.Severity, f.RuleID, location, f.Message)
+}
+
+// Rule is a policy the generated objects have to comply with.
+type Rule interface {
+	// ID uniquely identifies the rule, eg. no-latest-tag.
+	ID() string
+	// Description describes what the rule requires.
+	Description() string
+	// Severity is the severity of the findings of the rule.
+	Severity() string
+	// Check returns the findings of the rule on `targets`, all of the objects
+	// generated for the component tree.
+	Check(targets []Target) []Finding
+}
+
+// newFinding returns a finding of `rule` on `target`.
+func newFinding(rule Rule, target Target, path string, message string) Finding {
+	return Finding{
+		RuleID:    rule.ID(),
+		Severity:  rule.Severity(),
+		Message:   message,
+		Component: target.Component,
+		File:      target.File,
+		Object:    target.Object.Identity().String(),
+		Path:      path,
+	}
+}
+
+// Run checks `targets` against `rules` and returns the findings, sorted by
+// file, object, path, and rule.
+func Run(rules []Rule, targets []Target) (findings []Finding) {
+	for _, rule := range rules {
+		findings = append(findings, rule.Check(targets)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.RuleID < b.RuleID
+	})
+
+	return findings
+}
+
+// EncryptedManifestID is the rule ID of the findings on encrypted manifests,
+// which are not linted as only generate decrypts them.
+const EncryptedManifestID = "encrypted-manifest"
+
+// EncryptedManifestFinding returns the finding on the encrypted manifest at
+// `file` of `component`, which was left out of the linted objects.
+func EncryptedManifestFinding(component string, file string) Finding {
+	return Finding{
+		RuleID:    EncryptedManifestID,
+		Severity:  SeverityWarning,
+		Message:   "encrypted manifest is not linted; only generate decrypts manifests",
+		Component: component,
+		File:      file,
+	}
+}
+
+// HasErrors returns whether any of `findings` has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,187 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/microsoft/fabrikate/internal/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseTargets(t *testing.T, manifests string) (targets []Target) {
+	objects, err := manifest.Parse(manifests)
+	assert.Nil(t, err)
+	for _, object := range objects {
+		targets = append(targets, Target{Component: "app", File: "app.yaml", Object: object})
+	}
+
+	return targets
+}
+
+func TestBuiltinRules(t *testing.T) {
+	targets := parseTargets(t, `
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: jobs
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              image: backup:1.0
+              securityContext:
+                privileged: true
+`)
+
+	findings := Run(BuiltinRules(), targets)
+	assert.Equal(t, 2, len(findings))
+	assert.Equal(t, "resource-limits", findings[0].RuleID)
+	assert.Equal(t, "spec.jobTemplate.spec.template.spec.containers[0].resources.limits", findings[0].Path)
+	assert.Equal(t, "container 'backup' sets no cpu or memory limit", findings[0].Message)
+	assert.Equal(t, "no-privileged", findings[1].RuleID)
+	assert.Equal(t, "batch/v1beta1 CronJob jobs/backup", findings[1].Object)
+}
+
+func TestParsePath(t *testing.T) {
+	parts, err := parsePath(`$.metadata.annotations["example.com/team"]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []pathPart{{key: "metadata"}, {key: "annotations"}, {key: "example.com/team"}}, parts)
+
+	parts, err = parsePath("spec.containers[*].ports[0]")
+	assert.Nil(t, err)
+	assert.Equal(t, []pathPart{{key: "spec"}, {key: "containers"}, {wildcard: true}, {key: "ports"}, {index: 0, isIndex: true}}, parts)
+	assert.Equal(t, "spec.containers[*].ports[0]", formatPathParts(parts))
+
+	for _, invalid := range []string{"$", "spec..containers", "spec.containers[", "spec.containers[-1]", "spec[0]x"} {
+		_, err = parsePath(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	configPath := path.Join(dir, "lint.yaml")
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte(`
+disable: [namespace-required, no-latest-tag, resource-limits]
+rules:
+  - id: probes
+    description: Containers have probes
+    match:
+      kinds: [Pod]
+    assert:
+      - path: spec.containers[*].readinessProbe
+        exists: true
+      - path: spec.hostNetwork
+        exists: false
+  - id: replicas
+    severity: warning
+    assert:
+      - path: spec.replicas
+        equals: 3
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	assert.Nil(t, err)
+	rules, err := Rules(config)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(rules))
+
+	findings := Run(rules, parseTargets(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  hostNetwork: true
+  containers:
+    - name: web
+      readinessProbe: {}
+    - name: sidecar
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+`))
+	found := []string{}
+	for _, finding := range findings {
+		found = append(found, finding.String())
+	}
+	assert.Equal(t, []string{
+		"warning replicas: app.yaml: apps/v1 Deployment web: spec.replicas: 2, expected 3",
+		"error probes: app.yaml: v1 Pod web: spec.containers[1].readinessProbe: Containers have probes: not set",
+		"error probes: app.yaml: v1 Pod web: spec.hostNetwork: Containers have probes: set",
+	}, found)
+
+	// Invalid configs
+	for _, invalid := range []string{
+		"rules: [{id: a}]",
+		"rules: [{id: a, assert: [{path: spec}]}]",
+		"rules: [{id: a, severity: fatal, assert: [{path: spec, exists: true}]}]",
+		"rules: [{id: a, assert: [{path: spec, matches: '['}]}]",
+		"rule: []",
+	} {
+		assert.Nil(t, ioutil.WriteFile(configPath, []byte(invalid), 0644))
+		_, err = LoadConfig(configPath)
+		assert.NotNil(t, err, invalid)
+	}
+
+	_, err = Rules(Config{Disable: []string{"unknown"}})
+	assert.NotNil(t, err)
+	_, err = Rules(Config{Rules: []CustomRule{{RuleID: "no-duplicates"}}})
+	assert.NotNil(t, err)
+}
+
+func TestWriteReport(t *testing.T) {
+	rules := BuiltinRules()
+	findings := Run(rules, parseTargets(t, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: web
+spec:
+  containers:
+    - name: web
+      image: nginx
+      resources:
+        limits:
+          cpu: 1
+          memory: 1Gi
+`))
+
+	var text bytes.Buffer
+	assert.Nil(t, WriteReport(&text, FormatText, rules, findings))
+	assert.Equal(t, "error no-latest-tag: app.yaml: v1 Pod web/web: spec.containers[0].image: image 'nginx' has no tag\n1 errors, 0 warnings\n", text.String())
+
+	var jsonReport bytes.Buffer
+	assert.Nil(t, WriteReport(&jsonReport, FormatJSON, rules, findings))
+	decoded := []Finding{}
+	assert.Nil(t, json.Unmarshal(jsonReport.Bytes(), &decoded))
+	assert.Equal(t, findings, decoded)
+
+	var sarif bytes.Buffer
+	assert.Nil(t, WriteReport(&sarif, FormatSARIF, rules, findings))
+	log := sarifLog{}
+	assert.Nil(t, json.Unmarshal(sarif.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Equal(t, len(rules), len(log.Runs[0].Tool.Driver.Rules))
+	assert.Equal(t, "no-latest-tag", log.Runs[0].Results[0].RuleID)
+	assert.Equal(t, "app.yaml", log.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "v1 Pod web/web: spec.containers[0].image", log.Runs[0].Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+
+	assert.NotNil(t, WriteReport(&text, "xml", rules, findings))
+}
@@ -0,0 +1,154 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Report formats of findings.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// WriteReport writes `findings` of `rules` to `out` in `format`.
+func WriteReport(out io.Writer, format string, rules []Rule, findings []Finding) error {
+	switch format {
+	case FormatText, "":
+		return writeText(out, findings)
+	case FormatJSON:
+		return writeJSON(out, findings)
+	case FormatSARIF:
+		return writeSARIF(out, rules, findings)
+	}
+
+	return fmt.Errorf("unknown report format '%s', expected %s, %s, or %s", format, FormatText, FormatJSON, FormatSARIF)
+}
+
+// writeText writes one finding per line, followed by a summary.
+func writeText(out io.Writer, findings []Finding) error {
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			errors++
+		}
+		if _, err := fmt.Fprintln(out, finding); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(out, "%d errors, %d warnings\n", errors, len(findings)-errors)
+	return err
+}
+
+// writeJSON writes the findings as a JSON list.
+func writeJSON(out io.Writer, findings []Finding) error {
+	if findings == nil {
+		findings = []Finding{}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(findings)
+}
+
+// The subset of the SARIF 2.1.0 format written by writeSARIF.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// writeSARIF writes the findings as a SARIF 2.1.0 log, as consumed by code
+// scanning tools.
+func writeSARIF(out io.Writer, rules []Rule, findings []Finding) error {
+	driver := sarifDriver{Name: "fabrikate", InformationURI: "https://github.com/microsoft/fabrikate", Rules: []sarifRule{}}
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID(),
+			ShortDescription:     sarifMessage{Text: rule.Description()},
+			DefaultConfiguration: sarifConfiguration{Level: rule.Severity()},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, finding := range findings {
+		name := finding.Object
+		if finding.Path != "" {
+			name = fmt.Sprintf("%s: %s", name, finding.Path)
+		}
+		results = append(results, sarifResult{
+			RuleID:  finding.RuleID,
+			Level:   finding.Severity,
+			Message: sarifMessage{Text: finding.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: finding.File}},
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: name, Kind: "object"}},
+			}},
+		})
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
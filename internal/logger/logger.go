@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"sync"
 
@@ -10,6 +11,17 @@ import (
 // lock is a global mutex lock to gain control of logrus.<SetLevel|SetOutput>
 var lock = sync.Mutex{}
 
+// stdout is where the logs going to stdout are written to
+var stdout io.Writer = os.Stdout
+
+// SetStdout redirects the logs going to stdout to `w`, eg. to stderr when the
+// output of a command is written to stdout.
+func SetStdout(w io.Writer) {
+	lock.Lock()
+	stdout = w
+	lock.Unlock()
+}
+
 // SetLevelDebug sets the standard logger level to Debug
 func SetLevelDebug() {
 	lock.Lock()
@@ -27,7 +39,7 @@ func SetLevelInfo() {
 // Trace logs a message at level Trace to stdout.
 func Trace(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Trace(args...)
 	lock.Unlock()
 }
@@ -35,7 +47,7 @@ func Trace(args ...interface{}) {
 // Tracef logs a message at level Trace to stdout.
 func Tracef(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Tracef(format, args...)
 	lock.Unlock()
 }
@@ -43,7 +55,7 @@ func Tracef(format string, args ...interface{}) {
 // Traceln logs a message at level Trace to stdout.
 func Traceln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Traceln(args...)
 	lock.Unlock()
 }
@@ -51,7 +63,7 @@ func Traceln(args ...interface{}) {
 // Debug logs a message at level Debug to stdout.
 func Debug(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Debug(args...)
 	lock.Unlock()
 }
@@ -59,7 +71,7 @@ func Debug(args ...interface{}) {
 // Debugf logs a message at level Debug to stdout.
 func Debugf(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Debugf(format, args...)
 	lock.Unlock()
 }
@@ -67,7 +79,7 @@ func Debugf(format string, args ...interface{}) {
 // Debugln logs a message at level Debug to stdout.
 func Debugln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Debugln(args...)
 	lock.Unlock()
 }
@@ -75,7 +87,7 @@ func Debugln(args ...interface{}) {
 // Info logs a message at level Info to stdout.
 func Info(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Info(args...)
 	lock.Unlock()
 }
@@ -83,7 +95,7 @@ func Info(args ...interface{}) {
 // Infof logs a message at level Info to stdout.
 func Infof(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Infof(format, args...)
 	lock.Unlock()
 }
@@ -91,7 +103,7 @@ func Infof(format string, args ...interface{}) {
 // Infoln logs a message at level Info to stdout.
 func Infoln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Infoln(args...)
 	lock.Unlock()
 }
@@ -99,7 +111,7 @@ func Infoln(args ...interface{}) {
 // Warn logs a message at level Warn to stdout.
 func Warn(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Warn(args...)
 	lock.Unlock()
 }
@@ -107,7 +119,7 @@ func Warn(args ...interface{}) {
 // Warnf logs a message at level Warn to stdout.
 func Warnf(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Warnf(format, args...)
 	lock.Unlock()
 }
@@ -115,7 +127,7 @@ func Warnf(format string, args ...interface{}) {
 // Warnln logs a message at level Warn to stdout.
 func Warnln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Warnln(args...)
 	lock.Unlock()
 }
@@ -131,7 +143,7 @@ func Error(args ...interface{}) {
 // Errorf logs a message at level Error to stdout.
 func Errorf(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Errorf(format, args...)
 	lock.Unlock()
 }
@@ -139,7 +151,7 @@ func Errorf(format string, args ...interface{}) {
 // Errorln logs a message at level Error to stdout.
 func Errorln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Errorln(args...)
 	lock.Unlock()
 }
@@ -155,7 +167,7 @@ func Fatal(args ...interface{}) {
 // Fatalf logs a message at level Fatal to stdout.
 func Fatalf(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Fatalf(format, args...)
 	lock.Unlock()
 }
@@ -163,7 +175,7 @@ func Fatalf(format string, args ...interface{}) {
 // Fatalln logs a message at level Fatal to stdout.
 func Fatalln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Fatalln(args...)
 	lock.Unlock()
 }
@@ -179,7 +191,7 @@ func Panic(args ...interface{}) {
 // Panicf logs a message at level Panic to stdout.
 func Panicf(format string, args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Panicf(format, args...)
 	lock.Unlock()
 }
@@ -187,7 +199,7 @@ func Panicf(format string, args ...interface{}) {
 // Panicln logs a message at level Panic to stdout.
 func Panicln(args ...interface{}) {
 	lock.Lock()
-	logrus.SetOutput(os.Stdout)
+	logrus.SetOutput(stdout)
 	logrus.Panicln(args...)
 	lock.Unlock()
 }
@@ -0,0 +1,156 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldChange is a single field which differs between two versions of an
+// Object. A nil Before means the field was added; a nil After means it was
+// removed.
+type FieldChange struct {
+	Path   string
+	Before interface{}
+	After  interface{}
+}
+
+// String returns the change in the form `<path>: <before> -> <after>`.
+func (fc FieldChange) String() string {
+	switch {
+	case fc.Before == nil:
+		return fmt.Sprintf("%s: added %s", fc.Path, formatValue(fc.After))
+	case fc.After == nil:
+		return fmt.Sprintf("%s: removed %s", fc.Path, formatValue(fc.Before))
+	}
+
+	return fmt.Sprintf("%s: %s -> %s", fc.Path, formatValue(fc.Before), formatValue(fc.After))
+}
+
+// ObjectChange lists the fields which differ between two versions of the
+// Object with the given Identity.
+type ObjectChange struct {
+	Identity Identity
+	Fields   []FieldChange
+}
+
+// Diff is the difference between two sets of Objects, keyed by Identity.
+type Diff struct {
+	Added   []Identity
+	Removed []Identity
+	Changed []ObjectChange
+}
+
+// Empty returns whether the two sets of Objects are identical.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare returns the Objects added, removed, and changed going from `before`
+// to `after`, sorted by Identity. If the same Identity occurs more than once
+// in a set, the last occurrence is used.
+func Compare(before []Object, after []Object) (diff Diff) {
+	beforeByIdentity := indexByIdentity(before)
+	afterByIdentity := indexByIdentity(after)
+
+	for id, afterObject := range afterByIdentity {
+		beforeObject, exists := beforeByIdentity[id]
+		if !exists {
+			diff.Added = append(diff.Added, id)
+			continue
+		}
+
+		if fields := compareValues("", map[string]interface{}(beforeObject), map[string]interface{}(afterObject)); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ObjectChange{Identity: id, Fields: fields})
+		}
+	}
+	for id := range beforeByIdentity {
+		if _, exists := afterByIdentity[id]; !exists {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	sortIdentities(diff.Added)
+	sortIdentities(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Identity.String() < diff.Changed[j].Identity.String()
+	})
+
+	return diff
+}
+
+func indexByIdentity(objects []Object) map[Identity]Object {
+	index := map[Identity]Object{}
+	for _, object := range objects {
+		index[object.Identity()] = object
+	}
+
+	return index
+}
+
+func sortIdentities(ids []Identity) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+}
+
+// compareValues returns the changes between `before` and `after`, recursing
+// into maps and lists; `path` is the field path of the values.
+func compareValues(path string, before interface{}, after interface{}) (changes []FieldChange) {
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if beforeIsMap && afterIsMap {
+		keys := []string{}
+		for key := range beforeMap {
+			keys = append(keys, key)
+		}
+		for key := range afterMap {
+			if _, exists := beforeMap[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			changes = append(changes, compareValues(keyPath, beforeMap[key], afterMap[key])...)
+		}
+		return changes
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if beforeIsList && afterIsList {
+		for index := 0; index < len(beforeList) || index < len(afterList); index++ {
+			var beforeItem, afterItem interface{}
+			if index < len(beforeList) {
+				beforeItem = beforeList[index]
+			}
+			if index < len(afterList) {
+				afterItem = afterList[index]
+			}
+			changes = append(changes, compareValues(fmt.Sprintf("%s[%d]", path, index), beforeItem, afterItem)...)
+		}
+		return changes
+	}
+
+	return []FieldChange{{Path: path, Before: before, After: after}}
+}
+
+// formatValue formats a field value as compact JSON.
+func formatValue(value interface{}) string {
+	formatted, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(formatted)
+}
@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Selector selects objects by apiVersion, kind, namespace, and name. Each
+// field is a shell pattern (eg. `*.monitoring.coreos.com`); empty fields
+// match any value.
+type Selector struct {
+	APIVersion string `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Name       string `yaml:"name,omitempty" json:"name,omitempty"`
+}
+
+// Matches returns whether the object identified by `id` is selected.
+func (s Selector) Matches(id Identity) bool {
+	matches := func(pattern string, value string) bool {
+		if pattern == "" {
+			return true
+		}
+		matched, err := path.Match(pattern, value)
+		return err == nil && matched
+	}
+
+	return matches(s.APIVersion, id.APIVersion) && matches(s.Kind, id.Kind) && matches(s.Namespace, id.Namespace) && matches(s.Name, id.Name)
+}
+
+// documentSeparator matches the lines separating the documents of a multi-document YAML.
+var documentSeparator = regexp.MustCompile(`^---[ \t]*(#.*)?$`)
+
+// splitDocuments splits the multi-document YAML `manifests` into its documents,
+// each along with the separator line preceding it, such that joining them gives
+// back `manifests`.
+func splitDocuments(manifests string) (documents []string) {
+	document := ""
+	for _, line := range strings.SplitAfter(manifests, "\n") {
+		if documentSeparator.MatchString(strings.TrimRight(line, "\r\n")) && document != "" {
+			documents = append(documents, document)
+			document = ""
+		}
+		document += line
+	}
+	if document != "" {
+		documents = append(documents, document)
+	}
+
+	return documents
+}
+
+// Filter removes the objects for which `drop` returns true from the
+// multi-document YAML `manifests`, leaving the other documents untouched.
+// Returns the filtered manifests and the identities of the removed objects.
+func Filter(manifests string, drop func(id Identity) bool) (filtered string, removed []Identity, err error) {
+	for _, document := range splitDocuments(manifests) {
+		decoded := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(document), &decoded); err != nil {
+			return "", nil, err
+		}
+		object := Object(decoded)
+
+		// The objects of a `kind: List` are filtered from its items
+		if items, isList := object["items"].([]interface{}); isList && strings.HasSuffix(fmt.Sprint(object["kind"]), "List") {
+			kept := []interface{}{}
+			for _, item := range items {
+				if itemObject, ok := item.(map[string]interface{}); ok && drop(Object(itemObject).Identity()) {
+					removed = append(removed, Object(itemObject).Identity())
+					continue
+				}
+				kept = append(kept, item)
+			}
+
+			if len(kept) < len(items) {
+				object["items"] = kept
+				if document, err = Marshal(object); err != nil {
+					return "", nil, err
+				}
+			}
+			filtered += document
+			continue
+		}
+
+		if len(object) > 0 && drop(object.Identity()) {
+			removed = append(removed, object.Identity())
+			continue
+		}
+		filtered += document
+	}
+
+	return filtered, removed, nil
+}
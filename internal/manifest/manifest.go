@@ -0,0 +1,137 @@
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Object is a single Kubernetes resource manifest.
+type Object map[string]interface{}
+
+// Identity uniquely identifies an Object within a set of manifests.
+type Identity struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// String returns the identity in the form `<apiVersion> <kind> <namespace>/<name>`,
+// omitting the namespace of cluster scoped (or unnamespaced) objects.
+func (id Identity) String() string {
+	if id.Namespace == "" {
+		return fmt.Sprintf("%s %s %s", id.APIVersion, id.Kind, id.Name)
+	}
+
+	return fmt.Sprintf("%s %s %s/%s", id.APIVersion, id.Kind, id.Namespace, id.Name)
+}
+
+// Identity returns the apiVersion, kind, namespace, and name of the object.
+func (o Object) Identity() Identity {
+	id := Identity{}
+	id.APIVersion, _ = o["apiVersion"].(string)
+	id.Kind, _ = o["kind"].(string)
+	if metadata, ok := o["metadata"].(map[string]interface{}); ok {
+		id.Namespace, _ = metadata["namespace"].(string)
+		id.Name, _ = metadata["name"].(string)
+	}
+
+	return id
+}
+
+// Parse parses the multi-document YAML `manifests` into Objects, skipping
+// empty documents.
+func Parse(manifests string) (objects []Object, err error) {
+	decoder := yaml.NewDecoder(strings.NewReader(manifests))
+	for {
+		// Decode into a plain map; yaml.v3 decodes nested maps into the type of the outer one
+		decoded := map[string]interface{}{}
+		if err = decoder.Decode(&decoded); err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		object := Object(decoded)
+
+		// A `kind: List` holds its objects in `items`
+		if items, isList := object["items"].([]interface{}); isList && strings.HasSuffix(fmt.Sprint(object["kind"]), "List") {
+			for _, item := range items {
+				if itemObject, ok := item.(map[string]interface{}); ok && len(itemObject) > 0 {
+					objects = append(objects, Object(itemObject))
+				}
+			}
+			continue
+		}
+
+		if len(object) > 0 {
+			objects = append(objects, object)
+		}
+	}
+}
+
+// Marshal marshals `object` into a YAML document, starting with a document separator.
+func Marshal(object Object) (string, error) {
+	var marshaled strings.Builder
+	marshaled.WriteString("---\n")
+	encoder := yaml.NewEncoder(&marshaled)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}(object)); err != nil {
+		return "", err
+	}
+
+	return marshaled.String(), nil
+}
+
+// manifestFiles returns the paths of the YAML files under `dir`, in lexical
+// order, skipping kustomization files.
+func manifestFiles(dir string) (manifestPaths []string, err error) {
+	err = filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.EqualFold(strings.TrimSuffix(info.Name(), filepath.Ext(info.Name())), "kustomization") {
+			return nil
+		}
+		if extension := strings.ToLower(filepath.Ext(filePath)); extension == ".yaml" || extension == ".yml" {
+			manifestPaths = append(manifestPaths, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(manifestPaths)
+
+	return manifestPaths, nil
+}
+
+// ParseDir parses all of the YAML files under `dir` into Objects, in lexical
+// order of their paths. Kustomization files are skipped.
+func ParseDir(dir string) (objects []Object, err error) {
+	manifestPaths, err := manifestFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifestPath := range manifestPaths {
+		manifests, err := ioutil.ReadFile(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		fileObjects, err := Parse(string(manifests))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing '%s': %w", manifestPath, err)
+		}
+		objects = append(objects, fileObjects...)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,405 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	objects, err := Parse(`---
+# Source: chart/templates/empty.yaml
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: bar
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: bar
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(objects))
+	assert.Equal(t, Identity{APIVersion: "v1", Kind: "ConfigMap", Namespace: "bar", Name: "foo"}, objects[0].Identity())
+	assert.Equal(t, "v1 ConfigMap bar/foo", objects[0].Identity().String())
+	assert.Equal(t, "v1 Namespace bar", objects[1].Identity().String())
+
+	_, err = Parse("foo: [")
+	assert.NotNil(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	before, err := Parse(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: changed
+data:
+  removed: "1"
+  same: "2"
+  changed: "3"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: removed
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: same
+`)
+	assert.Nil(t, err)
+	after, err := Parse(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: same
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: changed
+data:
+  added: "4"
+  same: "2"
+  changed: "5"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: added
+`)
+	assert.Nil(t, err)
+
+	diff := Compare(before, after)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []Identity{{APIVersion: "v1", Kind: "ConfigMap", Name: "added"}}, diff.Added)
+	assert.Equal(t, []Identity{{APIVersion: "v1", Kind: "ConfigMap", Name: "removed"}}, diff.Removed)
+	assert.Equal(t, 1, len(diff.Changed))
+	assert.Equal(t, "changed", diff.Changed[0].Identity.Name)
+
+	fields := []string{}
+	for _, field := range diff.Changed[0].Fields {
+		fields = append(fields, field.String())
+	}
+	assert.Equal(t, []string{
+		`data.added: added "4"`,
+		`data.changed: "3" -> "5"`,
+		`data.removed: removed "1"`,
+	}, fields)
+
+	assert.True(t, Compare(before, before).Empty())
+}
+
+func TestFilter(t *testing.T) {
+	manifests := `# leading comment
+---
+# Source: chart/templates/crd.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+# Source: chart/templates/config.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: bar
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: baz
+  - apiVersion: rbac.authorization.k8s.io/v1
+    kind: ClusterRole
+    metadata:
+      name: reader
+`
+	selector := Selector{APIVersion: "apiextensions.k8s.io/*", Kind: "CustomResourceDefinition"}
+	assert.True(t, selector.Matches(Identity{APIVersion: "apiextensions.k8s.io/v1beta1", Kind: "CustomResourceDefinition", Name: "widgets.example.com"}))
+	assert.False(t, selector.Matches(Identity{APIVersion: "v1", Kind: "ConfigMap", Name: "foo"}))
+	assert.True(t, Selector{Name: "*.example.com"}.Matches(Identity{Name: "widgets.example.com"}))
+
+	filtered, removed, err := Filter(manifests, func(id Identity) bool {
+		return selector.Matches(id) || id.Kind == "ClusterRole"
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []Identity{
+		{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+		{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole", Name: "reader"},
+	}, removed)
+	assert.Equal(t, `# leading comment
+---
+# Source: chart/templates/config.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: bar
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: baz
+kind: List
+`, filtered)
+
+	// Nothing is dropped
+	filtered, removed, err = Filter(manifests, func(id Identity) bool { return false })
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(removed))
+	assert.Equal(t, manifests, filtered)
+}
+
+func TestNormalize(t *testing.T) {
+	manifests := `---
+# Source: chart/templates/deployment.yaml
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: web
+  namespace: web
+spec:
+  replicas: 2 # scaled by the autoscaler
+---
+---
+# Source: chart/templates/rbac.yaml
+kind: ClusterRole
+apiVersion: rbac.authorization.k8s.io/v1
+metadata:
+  name: reader
+---
+kind: Widget
+apiVersion: example.com/v1
+metadata:
+  name: widget
+---
+kind: Namespace
+apiVersion: v1
+metadata:
+  name: web
+`
+	normalized, err := Normalize(manifests)
+	assert.Nil(t, err)
+	assert.Equal(t, `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: web
+---
+# Source: chart/templates/rbac.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: web
+spec:
+  replicas: 2 # scaled by the autoscaler
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`, normalized)
+
+	// Normalizing is idempotent
+	renormalized, err := Normalize(normalized)
+	assert.Nil(t, err)
+	assert.Equal(t, normalized, renormalized)
+
+	stripped, err := Normalize(StripSourceComments(manifests))
+	assert.Nil(t, err)
+	assert.NotContains(t, stripped, "Source")
+	assert.Contains(t, stripped, "# scaled by the autoscaler")
+
+	_, err = Normalize("kind: [")
+	assert.NotNil(t, err)
+}
+
+func TestAddMetadata(t *testing.T) {
+	metadata := CommonMetadata{Labels: map[string]string{"team": "platform", "enabled": "true"}, Annotations: map[string]string{"owner": "platform"}}
+
+	updated, err := AddMetadata(`# leading comment
+---
+# Source: chart/templates/config.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  labels:
+    team: web # set by the chart
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers: []
+`, metadata)
+	assert.Nil(t, err)
+	assert.Equal(t, `# leading comment
+---
+# Source: chart/templates/config.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  labels:
+    team: web # set by the chart
+    enabled: "true"
+  annotations:
+    owner: platform
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      labels:
+        enabled: "true"
+        team: platform
+      annotations:
+        owner: platform
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+  labels:
+    enabled: "true"
+    team: platform
+  annotations:
+    owner: platform
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers: []
+`, updated)
+
+	// Templates and selectors
+	metadata.Templates, metadata.Selectors = true, true
+	updated, err = AddMetadata(`apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers: []
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: headless
+spec:
+  clusterIP: None
+`, metadata)
+	assert.Nil(t, err)
+	objects, err := Parse(updated)
+	assert.Nil(t, err)
+	jobTemplate := objects[0]["spec"].(map[string]interface{})["jobTemplate"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"team": "platform", "enabled": "true"}, jobTemplate["metadata"].(map[string]interface{})["labels"])
+	podTemplate := jobTemplate["spec"].(map[string]interface{})["template"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"owner": "platform"}, podTemplate["metadata"].(map[string]interface{})["annotations"])
+	assert.Equal(t, map[string]interface{}{"clusterIP": "None"}, objects[1]["spec"], "services without selector are left without one")
+
+	_, err = AddMetadata("kind: ConfigMap\nmetadata: config\n", metadata)
+	assert.NotNil(t, err)
+}
+
+func TestInjectNamespace(t *testing.T) {
+	manifests := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: bar
+  namespace: bar
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: clusterwidgets.example.com
+spec:
+  scope: Cluster
+  names:
+    kind: ClusterWidget
+---
+apiVersion: example.com/v1
+kind: ClusterWidget
+metadata:
+  name: widget
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: gadget
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: baz
+      namespace:
+`
+	injected, err := InjectNamespace(manifests, "foo", []string{"Gadget"})
+	assert.Nil(t, err)
+	objects, err := Parse(injected)
+	assert.Nil(t, err)
+	namespaces := []string{}
+	for _, object := range objects {
+		namespaces = append(namespaces, object.Identity().Namespace)
+	}
+	assert.Equal(t, []string{"foo", "bar", "", "", "", "", "foo"}, namespaces)
+	assert.Contains(t, injected, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n  namespace: foo\n")
+
+	namespace, err := NamespaceObject("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: foo\n", namespace)
+}
@@ -0,0 +1,238 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommonMetadata are labels and annotations added to every object of a set
+// of manifests.
+type CommonMetadata struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	Templates   bool // also add them to the pod (and job) templates of workloads
+	Selectors   bool // also add the labels to the selectors of workloads and services
+}
+
+// templatePaths are the paths of the pod and job templates of the workloads,
+// keyed by kind.
+var templatePaths = map[string][][]string{
+	"CronJob":               {{"spec", "jobTemplate"}, {"spec", "jobTemplate", "spec", "template"}},
+	"DaemonSet":             {{"spec", "template"}},
+	"Deployment":            {{"spec", "template"}},
+	"Job":                   {{"spec", "template"}},
+	"ReplicaSet":            {{"spec", "template"}},
+	"ReplicationController": {{"spec", "template"}},
+	"StatefulSet":           {{"spec", "template"}},
+}
+
+// selectorPaths are the paths of the label selectors of the workloads and
+// services, keyed by kind. Jobs are left out, as their selectors are
+// generated.
+var selectorPaths = map[string][]string{
+	"DaemonSet":             {"spec", "selector", "matchLabels"},
+	"Deployment":            {"spec", "selector", "matchLabels"},
+	"ReplicaSet":            {"spec", "selector", "matchLabels"},
+	"ReplicationController": {"spec", "selector"},
+	"Service":               {"spec", "selector"},
+	"StatefulSet":           {"spec", "selector", "matchLabels"},
+}
+
+// mappingValue returns the value of `key` in the mapping `node`, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		if node.Content[index].Value == key {
+			return node.Content[index+1]
+		}
+	}
+
+	return nil
+}
+
+// lookupPath returns the node at `keys` below the mapping `node`, or nil.
+func lookupPath(node *yaml.Node, keys []string) *yaml.Node {
+	for _, key := range keys {
+		if node = mappingValue(node, key); node == nil {
+			return nil
+		}
+	}
+
+	return node
+}
+
+// ensureMapping returns the mapping at `key` of the mapping `node`, adding
+// it if it is missing or null.
+func ensureMapping(node *yaml.Node, key string) (*yaml.Node, error) {
+	value := mappingValue(node, key)
+	if value != nil && value.Kind == yaml.MappingNode {
+		return value, nil
+	}
+	if value != nil && value.Tag != "!!null" {
+		return nil, fmt.Errorf("%s is not a map", key)
+	}
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if value != nil {
+		*value = *mapping
+		return value, nil
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, mapping)
+
+	return mapping, nil
+}
+
+// addEntries adds the entries of `values` missing from the map at `key` of
+// the mapping `node`, in order of keys. Returns whether any was added.
+func addEntries(node *yaml.Node, key string, values map[string]string) (added bool, err error) {
+	if len(values) == 0 {
+		return false, nil
+	}
+
+	mapping, err := ensureMapping(node, key)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{}
+	for valueKey := range values {
+		keys = append(keys, valueKey)
+	}
+	sort.Strings(keys)
+
+	for _, valueKey := range keys {
+		if mappingValue(mapping, valueKey) != nil {
+			continue
+		}
+		value := &yaml.Node{}
+		if err := value.Encode(values[valueKey]); err != nil {
+			return false, err
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: valueKey}, value)
+		added = true
+	}
+
+	return added, nil
+}
+
+// addMetadata adds the labels and annotations of `metadata` to the metadata
+// of the object `node`, and to its templates and selectors as requested.
+func (metadata CommonMetadata) addMetadata(node *yaml.Node) (added bool, err error) {
+	if node.Kind != yaml.MappingNode {
+		return false, nil
+	}
+
+	kind := ""
+	if kindNode := mappingValue(node, "kind"); kindNode != nil {
+		kind = kindNode.Value
+	}
+
+	// The objects of a `kind: List` are in its items
+	if items := mappingValue(node, "items"); items != nil && items.Kind == yaml.SequenceNode && strings.HasSuffix(kind, "List") {
+		for _, item := range items.Content {
+			itemAdded, err := metadata.addMetadata(item)
+			if err != nil {
+				return false, err
+			}
+			added = added || itemAdded
+		}
+		return added, nil
+	}
+
+	// Every object and template gets the labels and annotations
+	targets := []*yaml.Node{node}
+	if metadata.Templates {
+		for _, templatePath := range templatePaths[kind] {
+			if template := lookupPath(node, templatePath); template != nil && template.Kind == yaml.MappingNode {
+				targets = append(targets, template)
+			}
+		}
+	}
+
+	for _, target := range targets {
+		targetMetadata, err := ensureMapping(target, "metadata")
+		if err != nil {
+			return false, err
+		}
+		labelsAdded, err := addEntries(targetMetadata, "labels", metadata.Labels)
+		if err != nil {
+			return false, err
+		}
+		annotationsAdded, err := addEntries(targetMetadata, "annotations", metadata.Annotations)
+		if err != nil {
+			return false, err
+		}
+		added = added || labelsAdded || annotationsAdded
+	}
+
+	// Selectors only get the labels, and only if they select by label already
+	if selectorPath, ok := selectorPaths[kind]; ok && metadata.Selectors {
+		if selector := lookupPath(node, []string{"spec", "selector"}); selector != nil && selector.Kind == yaml.MappingNode {
+			parent, key := lookupPath(node, selectorPath[:len(selectorPath)-1]), selectorPath[len(selectorPath)-1]
+			selectorAdded, err := addEntries(parent, key, metadata.Labels)
+			if err != nil {
+				return false, err
+			}
+			added = added || selectorAdded
+		}
+	}
+
+	return added, nil
+}
+
+// AddMetadata adds the labels and annotations of `metadata` to every object
+// of the multi-document YAML `manifests` which does not set them already.
+// Documents left unchanged are kept as is.
+func AddMetadata(manifests string, metadata CommonMetadata) (string, error) {
+	if len(metadata.Labels) == 0 && len(metadata.Annotations) == 0 {
+		return manifests, nil
+	}
+
+	return rewriteDocuments(manifests, metadata.addMetadata, "error adding metadata to %s: %w")
+}
+
+// rewriteDocuments calls `rewrite` on the root node of every document of the
+// multi-document YAML `manifests`, re-encoding those it changed and keeping
+// the others as is. Errors are wrapped with `errorFormat`, given the identity
+// of the object.
+func rewriteDocuments(manifests string, rewrite func(node *yaml.Node) (changed bool, err error), errorFormat string) (string, error) {
+	var rewritten strings.Builder
+	for _, document := range splitDocuments(manifests) {
+		node := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(document), node); err != nil {
+			return "", err
+		}
+		if node.Kind != yaml.DocumentNode || len(node.Content) == 0 {
+			rewritten.WriteString(document)
+			continue
+		}
+
+		changed, err := rewrite(node.Content[0])
+		if err != nil {
+			decoded := map[string]interface{}{}
+			_ = node.Decode(&decoded)
+			return "", fmt.Errorf(errorFormat, Object(decoded).Identity(), err)
+		}
+		if !changed {
+			rewritten.WriteString(document)
+			continue
+		}
+
+		rewritten.WriteString("---\n")
+		encoder := yaml.NewEncoder(&rewritten)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(node); err != nil {
+			return "", err
+		}
+		if err := encoder.Close(); err != nil {
+			return "", err
+		}
+	}
+
+	return rewritten.String(), nil
+}
@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NamespaceObject returns the manifest of the Namespace `namespace`.
+func NamespaceObject(namespace string) (string, error) {
+	return Marshal(Object{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]interface{}{"name": namespace},
+	})
+}
+
+// InjectNamespace sets the namespace of every namespaced object of the
+// multi-document YAML `manifests` which does not set one to `namespace`.
+// Objects of the cluster scoped kinds (see ClusterScopedKinds) are left
+// without a namespace, as are those of `clusterScopedKinds`. Documents left
+// unchanged are kept as is.
+func InjectNamespace(manifests string, namespace string, clusterScopedKinds []string) (string, error) {
+	objects, err := Parse(manifests)
+	if err != nil {
+		return "", err
+	}
+	clusterScoped := ClusterScopedKinds(objects)
+	for _, kind := range clusterScopedKinds {
+		clusterScoped[kind] = true
+	}
+
+	var inject func(node *yaml.Node) (injected bool, err error)
+	inject = func(node *yaml.Node) (injected bool, err error) {
+		if node.Kind != yaml.MappingNode {
+			return false, nil
+		}
+
+		kind := ""
+		if kindNode := mappingValue(node, "kind"); kindNode != nil {
+			kind = kindNode.Value
+		}
+
+		// The objects of a `kind: List` are in its items
+		if items := mappingValue(node, "items"); items != nil && items.Kind == yaml.SequenceNode && strings.HasSuffix(kind, "List") {
+			for _, item := range items.Content {
+				itemInjected, err := inject(item)
+				if err != nil {
+					return false, err
+				}
+				injected = injected || itemInjected
+			}
+			return injected, nil
+		}
+
+		metadata := mappingValue(node, "metadata")
+		if clusterScoped[kind] || metadata == nil || metadata.Kind != yaml.MappingNode {
+			return false, nil
+		}
+		if current := mappingValue(metadata, "namespace"); current != nil && current.Value != "" {
+			return false, nil
+		}
+
+		value := &yaml.Node{}
+		if err := value.Encode(namespace); err != nil {
+			return false, err
+		}
+		if current := mappingValue(metadata, "namespace"); current != nil {
+			*current = *value
+		} else {
+			metadata.Content = append(metadata.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "namespace"}, value)
+		}
+
+		return true, nil
+	}
+
+	return rewriteDocuments(manifests, inject, fmt.Sprintf("error injecting namespace '%s' into %%s: %%w", namespace))
+}
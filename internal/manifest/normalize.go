@@ -0,0 +1,174 @@
+package manifest
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kindOrder is the order objects are sorted in by Normalize, such that the
+// objects others depend on come first: Namespaces, CustomResourceDefinitions,
+// and RBAC, then configuration, storage, and workloads. Objects of other kinds
+// (eg. custom resources) come after them, and webhook configurations, which
+// may reject objects until their service runs, last.
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"ServiceAccount",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"PriorityClass",
+	"PodSecurityPolicy",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodDisruptionBudget",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// lastKinds are the kinds sorted after the objects of all other kinds.
+var lastKinds = []string{
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// kindRank returns the position of `kind` in the order of Normalize.
+func kindRank(kind string) int {
+	for rank, orderedKind := range kindOrder {
+		if kind == orderedKind {
+			return rank
+		}
+	}
+	for rank, lastKind := range lastKinds {
+		if kind == lastKind {
+			return len(kindOrder) + 1 + rank
+		}
+	}
+
+	return len(kindOrder)
+}
+
+// sourceComment matches the `# Source: <template>` comment lines helm adds to
+// the objects it renders.
+var sourceComment = regexp.MustCompile(`(?m)^[ \t]*# Source: .*(\r?\n|$)`)
+
+// StripSourceComments removes helm's `# Source:` comments from the
+// multi-document YAML `manifests`.
+func StripSourceComments(manifests string) string {
+	return sourceComment.ReplaceAllString(manifests, "")
+}
+
+// sortKeys sorts the keys of the mappings in `node` and below, keeping the
+// comments of each key with it, but those heading a mapping (eg. helm's
+// `# Source:`) at its head.
+func sortKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode && len(node.Content) > 0 {
+		headComment := node.Content[0].HeadComment
+		node.Content[0].HeadComment = ""
+
+		pairs := [][2]*yaml.Node{}
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			pairs = append(pairs, [2]*yaml.Node{node.Content[index], node.Content[index+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i][0].Value < pairs[j][0].Value
+		})
+		node.Content = node.Content[:0]
+		for _, pair := range pairs {
+			node.Content = append(node.Content, pair[0], pair[1])
+		}
+		node.Content[0].HeadComment = headComment
+	}
+
+	for _, child := range node.Content {
+		sortKeys(child)
+	}
+}
+
+// Normalize rewrites the multi-document YAML `manifests` such that identical
+// objects give identical output, whatever the order they were generated in:
+// empty documents are removed, the objects are sorted by kind (see
+// kindOrder), apiVersion, namespace, and name, and the keys of every object
+// sorted alphabetically. Comments are kept.
+func Normalize(manifests string) (string, error) {
+	type document struct {
+		node     *yaml.Node
+		identity Identity
+	}
+
+	documents := []document{}
+	decoder := yaml.NewDecoder(strings.NewReader(manifests))
+	for {
+		node := &yaml.Node{}
+		if err := decoder.Decode(node); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		decoded := map[string]interface{}{}
+		if err := node.Decode(&decoded); err != nil {
+			return "", err
+		}
+		if len(decoded) == 0 {
+			continue
+		}
+
+		sortKeys(node)
+		documents = append(documents, document{node: node, identity: Object(decoded).Identity()})
+	}
+
+	sort.SliceStable(documents, func(i, j int) bool {
+		a, b := documents[i].identity, documents[j].identity
+		if rankA, rankB := kindRank(a.Kind), kindRank(b.Kind); rankA != rankB {
+			return rankA < rankB
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.APIVersion != b.APIVersion {
+			return a.APIVersion < b.APIVersion
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	var normalized strings.Builder
+	for _, document := range documents {
+		normalized.WriteString("---\n")
+		encoder := yaml.NewEncoder(&normalized)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document.node); err != nil {
+			return "", err
+		}
+		if err := encoder.Close(); err != nil {
+			return "", err
+		}
+	}
+
+	return normalized.String(), nil
+}
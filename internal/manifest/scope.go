@@ -0,0 +1,54 @@
+package manifest
+
+// clusterScopedKinds are the kinds of the built-in Kubernetes objects which
+// are not namespaced.
+var clusterScopedKinds = []string{
+	"APIService",
+	"CertificateSigningRequest",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"ComponentStatus",
+	"CSIDriver",
+	"CSINode",
+	"CustomResourceDefinition",
+	"FlowSchema",
+	"IngressClass",
+	"MutatingWebhookConfiguration",
+	"Namespace",
+	"Node",
+	"PersistentVolume",
+	"PodSecurityPolicy",
+	"PriorityClass",
+	"PriorityLevelConfiguration",
+	"RuntimeClass",
+	"SelfSubjectAccessReview",
+	"SelfSubjectRulesReview",
+	"StorageClass",
+	"SubjectAccessReview",
+	"TokenReview",
+	"ValidatingWebhookConfiguration",
+	"VolumeAttachment",
+}
+
+// ClusterScopedKinds returns the kinds of objects which are not namespaced:
+// those of the built-in Kubernetes objects, and those of the custom resources
+// defined with `scope: Cluster` by the CustomResourceDefinitions in `objects`.
+func ClusterScopedKinds(objects []Object) map[string]bool {
+	kinds := map[string]bool{}
+	for _, kind := range clusterScopedKinds {
+		kinds[kind] = true
+	}
+
+	for _, object := range objects {
+		if object.Identity().Kind != "CustomResourceDefinition" {
+			continue
+		}
+		spec, _ := object["spec"].(map[string]interface{})
+		names, _ := spec["names"].(map[string]interface{})
+		if kind, ok := names["kind"].(string); ok && spec["scope"] == "Cluster" {
+			kinds[kind] = true
+		}
+	}
+
+	return kinds
+}
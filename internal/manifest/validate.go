@@ -0,0 +1,338 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// DefaultSchemaLocation is the location of the standalone JSON schemas of the
+// Kubernetes objects of every Kubernetes version, generated from the OpenAPI
+// specification of Kubernetes. The schemas are not bundled with fab, as those
+// of every version weigh hundreds of megabytes: the schemas downloaded are kept
+// in the install cache instead, such that validating offline uses them.
+const DefaultSchemaLocation = "https://raw.githubusercontent.com/yannh/kubernetes-json-schema/master"
+
+// defaultSchemaPath is the path of a schema within a schema location which is
+// not a template.
+const defaultSchemaPath = "{{ .NormalizedKubernetesVersion }}-standalone{{ .StrictSuffix }}/{{ .ResourceKind }}{{ .KindSuffix }}.json"
+
+// errSchemaNotFound is returned when a schema location has no schema for a kind
+var errSchemaNotFound = errors.New("schema not found")
+
+// Violation is a generated object which does not match the schema of its kind.
+type Violation struct {
+	File        string
+	Identity    Identity
+	Field       string
+	Description string
+}
+
+// String returns the violation in the form `<file>: <object>: <field>: <description>`.
+func (v Violation) String() string {
+	if v.Field == "" {
+		return fmt.Sprintf("%s: %s: %s", v.File, v.Identity, v.Description)
+	}
+
+	return fmt.Sprintf("%s: %s: %s: %s", v.File, v.Identity, v.Field, v.Description)
+}
+
+// SchemaValidator validates objects against the JSON schemas of their kind for
+// a Kubernetes version, without a cluster. Schemas are looked up in the
+// locations in order; a location is a local directory or a http(s) URL, either
+// the root of a tree laid out as DefaultSchemaLocation or a template of the
+// path of a schema with the fields of schemaParams. Downloaded schemas are kept
+// in the install cache.
+type SchemaValidator struct {
+	KubernetesVersion    string   // eg. 1.18.0, or master
+	Locations            []string // defaults to DefaultSchemaLocation
+	Strict               bool     // disallow fields not in the schemas
+	IgnoreMissingSchemas bool     // skip objects of kinds without a schema, eg. custom resources
+	Offline              bool     // only use local and cached schemas
+
+	mu      sync.Mutex
+	schemas map[string]*gojsonschema.Schema
+}
+
+// schemaParams are the fields of a schema location template.
+type schemaParams struct {
+	NormalizedKubernetesVersion string // eg. v1.18.0, or master
+	StrictSuffix                string // -strict for strict schemas
+	ResourceKind                string // eg. deployment
+	ResourceAPIVersion          string // eg. v1
+	Group                       string // eg. apps
+	KindSuffix                  string // eg. -apps-v1
+}
+
+// params returns the fields of a schema location template for `id`.
+func (v *SchemaValidator) params(id Identity) schemaParams {
+	params := schemaParams{
+		NormalizedKubernetesVersion: "master",
+		ResourceKind:                strings.ToLower(id.Kind),
+		ResourceAPIVersion:          id.APIVersion,
+	}
+	if v.KubernetesVersion != "" && v.KubernetesVersion != "master" {
+		params.NormalizedKubernetesVersion = "v" + strings.TrimPrefix(v.KubernetesVersion, "v")
+	}
+	if v.Strict {
+		params.StrictSuffix = "-strict"
+	}
+	if slash := strings.Index(id.APIVersion, "/"); slash >= 0 {
+		params.Group = strings.ToLower(strings.Split(id.APIVersion[:slash], ".")[0])
+		params.ResourceAPIVersion = id.APIVersion[slash+1:]
+		params.KindSuffix = "-" + params.Group
+	}
+	params.KindSuffix += "-" + strings.ToLower(params.ResourceAPIVersion)
+
+	return params
+}
+
+// schemaLocation returns the path or URL of the schema of `id` in `location`.
+func (v *SchemaValidator) schemaLocation(location string, id Identity) (string, error) {
+	if !strings.Contains(location, "{{") {
+		location = strings.TrimSuffix(location, "/") + "/" + defaultSchemaPath
+	}
+
+	locationTemplate, err := template.New("location").Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("error parsing schema location '%s': %w", location, err)
+	}
+
+	var schemaLocation bytes.Buffer
+	if err := locationTemplate.Execute(&schemaLocation, v.params(id)); err != nil {
+		return "", err
+	}
+
+	return schemaLocation.String(), nil
+}
+
+// loadSchema loads the schema of `id`, from the first location having one.
+func (v *SchemaValidator) loadSchema(id Identity) (*gojsonschema.Schema, error) {
+	locations := v.Locations
+	if len(locations) == 0 {
+		locations = []string{DefaultSchemaLocation}
+	}
+
+	// The lock is not held while reading the schemas, such that a download does not hold up the validation
+	// of objects of other kinds; a schema loaded concurrently is downloaded twice, and stored in the cache once
+	key := fmt.Sprintf("%s %s", id.APIVersion, id.Kind)
+	v.mu.Lock()
+	schema, loaded := v.schemas[key]
+	v.mu.Unlock()
+	if loaded {
+		return schema, nil
+	}
+
+	// Offline, locations whose schema is not cached are skipped for the next ones
+	var notCached error
+	for _, location := range locations {
+		schemaLocation, err := v.schemaLocation(location, id)
+		if err != nil {
+			return nil, err
+		}
+
+		schemaJSON, err := v.readSchema(schemaLocation)
+		if err == errSchemaNotFound {
+			continue
+		}
+		if errors.Is(err, cache.ErrNotCached) {
+			if notCached == nil {
+				notCached = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaJSON))
+		if err != nil {
+			return nil, fmt.Errorf("error loading schema '%s': %w", schemaLocation, err)
+		}
+
+		v.mu.Lock()
+		if v.schemas == nil {
+			v.schemas = map[string]*gojsonschema.Schema{}
+		}
+		v.schemas[key] = schema
+		v.mu.Unlock()
+		return schema, nil
+	}
+	if notCached != nil {
+		return nil, notCached
+	}
+
+	return nil, errSchemaNotFound
+}
+
+// CheckCached returns an error if, offline, none of the locations can have the
+// schemas of the Kubernetes version: none is local or a template, and no schema
+// of the version was cached from any of them. It lets validation fail before
+// the manifests are generated rather than on the first object.
+func (v *SchemaValidator) CheckCached() error {
+	if !v.Offline {
+		return nil
+	}
+
+	locations := v.Locations
+	if len(locations) == 0 {
+		locations = []string{DefaultSchemaLocation}
+	}
+
+	for _, location := range locations {
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") || strings.Contains(location, "{{") {
+			return nil
+		}
+
+		// The schemas of a version are laid out in a directory of their own
+		schemaLocation, err := v.schemaLocation(location, Identity{APIVersion: "v1", Kind: "ConfigMap"})
+		if err != nil {
+			return err
+		}
+		entryPath, err := cache.SchemaPath(schemaLocation)
+		if err != nil {
+			return err
+		}
+		if cached, err := ioutil.ReadDir(path.Dir(entryPath)); err == nil && len(cached) > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no schemas of Kubernetes %s are cached, and they cannot be downloaded offline; validate once with network access to cache them, or pass a local --schema-location", v.params(Identity{}).NormalizedKubernetesVersion)
+}
+
+// readSchema reads the schema at `schemaLocation`, a local path or a http(s)
+// URL. Schemas downloaded are stored in the install cache.
+func (v *SchemaValidator) readSchema(schemaLocation string) ([]byte, error) {
+	if !strings.HasPrefix(schemaLocation, "http://") && !strings.HasPrefix(schemaLocation, "https://") {
+		schemaJSON, err := ioutil.ReadFile(strings.TrimPrefix(schemaLocation, "file://"))
+		if os.IsNotExist(err) {
+			return nil, errSchemaNotFound
+		}
+		return schemaJSON, err
+	}
+
+	entryPath, err := cache.SchemaPath(schemaLocation)
+	if err != nil {
+		return nil, err
+	}
+	if cache.Lookup(entryPath) {
+		return ioutil.ReadFile(path.Join(entryPath, cache.SchemaFilename))
+	}
+	if v.Offline {
+		return nil, fmt.Errorf("schema '%s' %w; validate once with network access to cache it, or pass a local --schema-location", schemaLocation, cache.ErrNotCached)
+	}
+
+	response, err := http.Get(schemaLocation)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading schema '%s': %w; pass --offline to validate against the cached schemas, or a local --schema-location", schemaLocation, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, errSchemaNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading schema '%s': %s", schemaLocation, response.Status)
+	}
+	schemaJSON, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := cache.TempDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path.Join(tmpDir, cache.SchemaFilename), schemaJSON, 0644); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if err := cache.Store(tmpDir, entryPath); err != nil {
+		return nil, err
+	}
+
+	return schemaJSON, nil
+}
+
+// Validate validates `object`, read from `file`, against the schema of its kind.
+func (v *SchemaValidator) Validate(file string, object Object) (violations []Violation, err error) {
+	id := object.Identity()
+	if id.APIVersion == "" || id.Kind == "" {
+		return []Violation{{File: file, Identity: id, Description: "object has no apiVersion or kind"}}, nil
+	}
+
+	schema, err := v.loadSchema(id)
+	if err == errSchemaNotFound {
+		if v.IgnoreMissingSchemas {
+			return nil, nil
+		}
+		return []Violation{{File: file, Identity: id, Description: fmt.Sprintf("no schema found for %s %s in Kubernetes %s", id.APIVersion, id.Kind, v.params(id).NormalizedKubernetesVersion)}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := schema.Validate(gojsonschema.NewGoLoader(map[string]interface{}(object)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resultError := range result.Errors() {
+		field := resultError.Field()
+		if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			field = ""
+		}
+		violations = append(violations, Violation{File: file, Identity: id, Field: field, Description: resultError.Description()})
+	}
+
+	// The schema errors are in no particular order
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Field != violations[j].Field {
+			return violations[i].Field < violations[j].Field
+		}
+		return violations[i].Description < violations[j].Description
+	})
+
+	return violations, nil
+}
+
+// ValidateDir validates all of the objects in the YAML files under `dir`
+// (see ParseDir).
+func (v *SchemaValidator) ValidateDir(dir string) (violations []Violation, err error) {
+	manifestPaths, err := manifestFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, manifestPath := range manifestPaths {
+		manifests, err := ioutil.ReadFile(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := Parse(string(manifests))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing '%s': %w", manifestPath, err)
+		}
+
+		for _, object := range objects {
+			objectViolations, err := v.Validate(manifestPath, object)
+			if err != nil {
+				return nil, err
+			}
+			violations = append(violations, objectViolations...)
+		}
+	}
+
+	return violations, nil
+}
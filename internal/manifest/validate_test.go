@@ -0,0 +1,185 @@
+package manifest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/fabrikate/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaLocation(t *testing.T) {
+	validator := SchemaValidator{KubernetesVersion: "1.18.0"}
+
+	location, err := validator.schemaLocation("https://example.com/schemas/", Identity{APIVersion: "networking.k8s.io/v1beta1", Kind: "Ingress"})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/schemas/v1.18.0-standalone/ingress-networking-v1beta1.json", location)
+
+	validator = SchemaValidator{Strict: true}
+	location, err = validator.schemaLocation("./schemas", Identity{APIVersion: "v1", Kind: "ConfigMap"})
+	assert.Nil(t, err)
+	assert.Equal(t, "./schemas/master-standalone-strict/configmap-v1.json", location)
+
+	location, err = validator.schemaLocation("./crds/{{ .Group }}/{{ .ResourceKind }}_{{ .ResourceAPIVersion }}.json", Identity{APIVersion: "example.com/v1", Kind: "Widget"})
+	assert.Nil(t, err)
+	assert.Equal(t, "./crds/example/widget_v1.json", location)
+}
+
+func TestValidateDir(t *testing.T) {
+	validator := SchemaValidator{Locations: []string{"../../testdata/validate-schema/schemas"}}
+
+	violations, err := validator.ValidateDir("../../testdata/validate-schema/manifests")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(violations))
+	assert.Equal(t, "../../testdata/validate-schema/manifests/broken/deployment.yaml: apps/v1 Deployment broken: spec.replicas: Invalid type. Expected: integer, given: string", violations[0].String())
+	assert.Equal(t, "spec.template.spec.containers.0", violations[1].Field)
+	assert.Equal(t, "name is required", violations[1].Description)
+	assert.Equal(t, "../../testdata/validate-schema/manifests/widget/widget.yaml: example.com/v1 Widget widget: no schema found for example.com/v1 Widget in Kubernetes master", violations[2].String())
+
+	// Kinds without a schema are skipped
+	validator.IgnoreMissingSchemas = true
+	violations, err = validator.ValidateDir("../../testdata/validate-schema/manifests/widget")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(violations))
+
+	// The schemas of the version, in order of location
+	validator = SchemaValidator{KubernetesVersion: "v1.18.0", Locations: []string{"../../testdata/validate-schema/schemas", "../../testdata/validate-schema/schemas/master-standalone/{{ .ResourceKind }}{{ .KindSuffix }}.json"}}
+	violations, err = validator.ValidateDir("../../testdata/validate-schema/manifests/app")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(violations))
+}
+
+func TestValidateDownloadsSchemas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache.SetDir(dir)
+	defer cache.SetDir("")
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeFile(w, r, "../../testdata/validate-schema/schemas"+r.URL.Path)
+	}))
+	defer server.Close()
+
+	objects, err := Parse(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  replicas: 2
+`)
+	assert.Nil(t, err)
+
+	// Offline, validation fails early as long as none of the schemas are cached
+	validator := SchemaValidator{Locations: []string{server.URL}, Offline: true}
+	assert.NotNil(t, validator.CheckCached())
+	validator = SchemaValidator{Locations: []string{server.URL, "../../testdata/validate-schema/schemas"}, Offline: true}
+	assert.Nil(t, validator.CheckCached())
+
+	validator = SchemaValidator{Locations: []string{server.URL}}
+	assert.Nil(t, validator.CheckCached())
+	violations, err := validator.Validate("configmap.yaml", objects[0])
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, "data.replicas", violations[0].Field)
+	assert.Equal(t, 1, requests)
+
+	// Offline, the schema is read from the cache
+	validator = SchemaValidator{Locations: []string{server.URL}, Offline: true}
+	assert.Nil(t, validator.CheckCached())
+	assert.NotNil(t, (&SchemaValidator{KubernetesVersion: "1.18.0", Locations: []string{server.URL}, Offline: true}).CheckCached())
+	violations, err = validator.Validate("configmap.yaml", objects[0])
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, 1, requests)
+
+	entries, err := cache.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, cache.SchemaKind, entries[0].Kind)
+	assert.Equal(t, "configmap-v1.json", entries[0].Key)
+
+	// Offline, schemas not in the cache are not downloaded
+	objects, err = Parse(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`)
+	assert.Nil(t, err)
+	_, err = validator.Validate("deployment.yaml", objects[0])
+	assert.True(t, errors.Is(err, cache.ErrNotCached))
+	assert.Equal(t, 1, requests)
+
+	// but looked up in the next locations
+	validator = SchemaValidator{Locations: []string{server.URL, "../../testdata/validate-schema/schemas"}, Offline: true}
+	violations, err = validator.Validate("deployment.yaml", objects[0])
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, "spec is required", violations[0].Description)
+	assert.Equal(t, 1, requests)
+}
+
+func TestValidateDownloadsSchemasConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fabrikate")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cache.SetDir(dir)
+	defer cache.SetDir("")
+
+	// The download of the Deployment schema hangs until the ConfigMap is validated
+	validated, timedOut := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "deployment") {
+			select {
+			case <-validated:
+			case <-timedOut:
+			}
+		}
+		http.ServeFile(w, r, "../../testdata/validate-schema/schemas"+r.URL.Path)
+	}))
+	defer server.Close()
+
+	objects, err := Parse(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`)
+	assert.Nil(t, err)
+
+	validator := SchemaValidator{Locations: []string{server.URL}}
+	done := make(chan error)
+	go func() {
+		_, err := validator.Validate("deployment.yaml", objects[0])
+		done <- err
+	}()
+
+	go func() {
+		_, err := validator.Validate("configmap.yaml", objects[1])
+		assert.Nil(t, err)
+		close(validated)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(10 * time.Second):
+		close(timedOut)
+		t.Fatal("validating a ConfigMap waited for the download of the schema of a Deployment")
+	}
+}